@@ -1,21 +1,58 @@
 package badger
 
 import (
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/WindomZ/gkv"
 	"github.com/dgraph-io/badger"
 )
 
+// Options are params for creating a badger driver.
+type Options struct {
+	// GCInterval is the interval of the value log garbage collection
+	// running in background, zero disables it.
+	GCInterval time.Duration
+	// GCDiscardRatio is the ratio of discardable space of a value log file
+	// to get it rewritten, it must be in the range (0.0, 1.0).
+	GCDiscardRatio float64
+	// Logger logs the value log garbage collection running in background,
+	// nil disables it.
+	Logger *slog.Logger
+}
+
+// DefaultOptions sets a list of recommended options for good performance.
+var DefaultOptions = Options{
+	GCInterval:     10 * time.Minute,
+	GCDiscardRatio: 0.5,
+}
+
 // KV is dgraph-io/badger adapter.
 type KV struct {
-	db *badger.DB
+	db   *badger.DB
+	path string
+	sync atomic.Bool
+
+	discardRatio float64
+	logger       *slog.Logger
+	done         chan struct{}
+	gcDone       chan struct{}
+	closeOnce    sync.Once
 }
 
 // Open creates a new badger driver by storage file path.
 // paths are storage file paths.
 func Open(paths ...string) gkv.KV {
+	return OpenOptions(DefaultOptions, paths...)
+}
+
+// OpenOptions creates a new badger driver by options and storage file path.
+// paths are storage file paths.
+func OpenOptions(opts Options, paths ...string) gkv.KV {
 	var path string
 	if len(paths) != 0 {
 		path = paths[0]
@@ -34,30 +71,71 @@ func Open(paths ...string) gkv.KV {
 		path = filepath.Dir(path)
 	}
 
-	opts := badger.DefaultOptions
-	opts.Dir = path
-	opts.ValueDir = path
-	opts.MaxTableSize = 1 << 15
-	opts.LevelOneSize = 4 << 15
-	opts.SyncWrites = false
+	o := badger.DefaultOptions
+	o.Dir = path
+	o.ValueDir = path
+	o.MaxTableSize = 1 << 15
+	o.LevelOneSize = 4 << 15
+	o.SyncWrites = false
 
-	db, err := badger.Open(opts)
+	db, err := badger.Open(o)
 	if err != nil {
 		panic(err)
 	}
-	return &KV{
-		db: db,
+	kv := &KV{
+		db:           db,
+		path:         path,
+		discardRatio: opts.GCDiscardRatio,
+		logger:       opts.Logger,
+		done:         make(chan struct{}),
+	}
+	if opts.GCInterval > 0 {
+		kv.gcDone = make(chan struct{})
+		go kv.gc(opts.GCInterval)
+	}
+	return kv
+}
+
+// gc runs the value log garbage collection every interval until closed.
+func (kv *KV) gc(interval time.Duration) {
+	defer close(kv.gcDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			err := kv.Compact()
+			if kv.logger == nil {
+				break
+			}
+			if err != nil {
+				kv.logger.Error("gkv badger gc", "path", kv.path, "err", err)
+			} else {
+				kv.logger.Debug("gkv badger gc", "path", kv.path,
+					"duration", time.Since(start))
+			}
+		case <-kv.done:
+			return
+		}
 	}
 }
 
 // DB returns the native DB of the adapter.
-func (kv KV) DB() interface{} {
+func (kv *KV) DB() interface{} {
 	return kv.db
 }
 
-// Close releases all database resources.
-func (kv *KV) Close() error {
-	return kv.db.Close()
+// Close releases all database resources, it does nothing if it is closed.
+func (kv *KV) Close() (err error) {
+	kv.closeOnce.Do(func() {
+		close(kv.done)
+		if kv.gcDone != nil {
+			<-kv.gcDone
+		}
+		err = kv.db.Close()
+	})
+	return
 }
 
 // Register initializes a new database if it doesn't already exist.
@@ -67,9 +145,13 @@ func (kv *KV) Register(table []byte) error {
 
 // Put sets the value for a key.
 func (kv *KV) Put(key, value []byte) error {
-	return kv.db.Update(func(txn *badger.Txn) error {
+	err := kv.db.Update(func(txn *badger.Txn) error {
 		return txn.Set(key, value)
 	})
+	if err == nil && kv.sync.Load() {
+		err = kv.Sync()
+	}
+	return err
 }
 
 // Get retrieves the value for a key.
@@ -87,9 +169,13 @@ func (kv *KV) Get(key []byte) (value []byte) {
 
 // Delete deletes the given key from the database resources.
 func (kv *KV) Delete(key []byte) error {
-	return kv.db.Update(func(txn *badger.Txn) error {
+	err := kv.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete(key)
 	})
+	if err == nil && kv.sync.Load() {
+		err = kv.Sync()
+	}
+	return err
 }
 
 // Count returns the total number of all the keys.
@@ -108,8 +194,8 @@ func (kv *KV) Count() (i int) {
 }
 
 // Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
-	return kv.db.View(func(txn *badger.Txn) error {
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	err := kv.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		it := txn.NewIterator(opts)
@@ -121,13 +207,69 @@ func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
 				if err != nil {
 					return err
 				}
-				if !f(k, v) {
-					break
+				if err = f(k, v); err != nil {
+					return err
 				}
 			}
 		}
 		return nil
 	})
+	if err == gkv.ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	kv.sync.Store(sync)
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	// every write is appended to the value log before it is applied,
+	// so flushing the value log files is enough.
+	files, err := filepath.Glob(filepath.Join(kv.path, "*.vlog"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = gkv.SyncFile(file); err != nil {
+			return err
+		}
+	}
+	return gkv.SyncFile(kv.path)
+}
+
+// Compact reclaims the unused space of the storage,
+// it rewrites value log files until there is nothing to discard.
+func (kv *KV) Compact() error {
+	for {
+		switch err := kv.db.RunValueLogGC(kv.discardRatio); err {
+		case nil:
+		case badger.ErrNoRewrite, badger.ErrRejected:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// Stats returns the statistics of the storage,
+// the sizes of the LSM tree and the value log are the backend-specific ones,
+// which badger updates periodically.
+func (kv *KV) Stats() gkv.Stats {
+	lsm, vlog := kv.db.Size()
+	tables, _ := filepath.Glob(filepath.Join(kv.path, "*.sst"))
+	return gkv.Stats{
+		Keys: kv.Count(),
+		Size: gkv.DiskSize(kv.path),
+		Backend: map[string]interface{}{
+			"lsm_size":  lsm,
+			"vlog_size": vlog,
+			"tables":    len(tables),
+		},
+	}
 }
 
 func init() {
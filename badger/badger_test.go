@@ -1,8 +1,12 @@
 package badger
 
 import (
+	"errors"
+	"log/slog"
 	"testing"
+	"time"
 
+	"github.com/WindomZ/gkv"
 	"github.com/WindomZ/testify/assert"
 )
 
@@ -42,12 +46,21 @@ func TestCount(t *testing.T) {
 
 func TestIterator(t *testing.T) {
 	cnt := 0
-	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) bool {
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
 		cnt++
-		return assert.Equal(t, demoKey, k) &&
-			assert.Equal(t, demoValue, v)
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
 	}))
 	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
 }
 
 func TestDelete(t *testing.T) {
@@ -55,6 +68,76 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, demo.Count())
 }
 
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+
+	// it may be set while writing.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			demo.SetSync(i%2 == 0)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, demo.Put(demoKey, demoValue))
+	}
+	<-done
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Contains(t, s.Backend, "lsm_size")
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
 func TestClose(t *testing.T) {
 	assert.NoError(t, demo.Close())
 }
+
+// logWriter passes the lines logged to a channel.
+type logWriter chan string
+
+func (w logWriter) Write(p []byte) (int, error) {
+	select {
+	case w <- string(p):
+	default:
+	}
+	return len(p), nil
+}
+
+func TestGCLogger(t *testing.T) {
+	lines := make(logWriter, 16)
+	kv := OpenOptions(Options{
+		GCInterval:     10 * time.Millisecond,
+		GCDiscardRatio: 0.5,
+		Logger:         slog.New(slog.NewTextHandler(lines, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}, "../data/badger.db")
+	select {
+	case line := <-lines:
+		assert.Contains(t, line, `msg="gkv badger gc"`)
+	case <-time.After(5 * time.Second):
+		t.Error("no garbage collection is logged")
+	}
+	assert.NoError(t, kv.Close())
+	assert.NoError(t, kv.Close())
+}
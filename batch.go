@@ -0,0 +1,72 @@
+package gkv
+
+// Batch is a sequence of writes, applied in order by Apply.
+type Batch struct {
+	writes []write
+}
+
+// write is a put or a delete of a key.
+type write struct {
+	key, value []byte
+	delete     bool
+}
+
+// Put appends a put of the value for a key.
+func (b *Batch) Put(key, value []byte) {
+	b.writes = append(b.writes, write{key: key, value: value})
+}
+
+// Delete appends a delete of the key.
+func (b *Batch) Delete(key []byte) {
+	b.writes = append(b.writes, write{key: key, delete: true})
+}
+
+// Len returns the number of writes.
+func (b *Batch) Len() int {
+	return len(b.writes)
+}
+
+// Reset removes all the writes.
+func (b *Batch) Reset() {
+	b.writes = b.writes[:0]
+}
+
+// Replay calls put or del for every write in order,
+// it stops at the first error.
+func (b *Batch) Replay(put func([]byte, []byte) error, del func([]byte) error) error {
+	for _, w := range b.writes {
+		var err error
+		if w.delete {
+			err = del(w.key)
+		} else {
+			err = put(w.key, w.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply writes the batch into kv, atomically if kv is a Batcher,
+// otherwise one write after another.
+func (b *Batch) Apply(kv KV) error {
+	if w, ok := kv.(Batcher); ok {
+		return w.Write(b)
+	}
+	return b.Replay(kv.Put, kv.Delete)
+}
+
+// Batcher is implemented by the adapters which write a Batch atomically.
+type Batcher interface {
+	// Write writes all the writes of the batch atomically.
+	Write(*Batch) error
+}
+
+// Prefixer is implemented by the adapters which iterate over
+// the keys with a prefix without visiting the others.
+type Prefixer interface {
+	// Prefix creates an iterator for iterating over the keys with the prefix,
+	// it stops as Iterator does.
+	Prefix([]byte, func([]byte, []byte) error) error
+}
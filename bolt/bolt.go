@@ -1,9 +1,10 @@
 package bolt
 
 import (
-	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/WindomZ/gkv"
 	"github.com/boltdb/bolt"
@@ -11,8 +12,68 @@ import (
 
 // KV is boltdb/bolt adapter.
 type KV struct {
-	db    *bolt.DB
-	table []byte
+	*conn
+	path [][]byte
+}
+
+// conn is the native DB shared by the KV and the KVs of its nested buckets,
+// mu is held exclusively by Compact while it replaces the DB.
+type conn struct {
+	mu lock
+	db *bolt.DB
+}
+
+// lock is a readers-writer lock preferring the readers, so that the read lock
+// can be taken again by the callbacks of Iterator and Walk while Compact
+// is waiting for the write lock, which sync.RWMutex doesn't allow.
+type lock struct {
+	mu      sync.Mutex
+	cond    sync.Cond
+	readers int
+	writing bool
+}
+
+// RLock locks for reading, it waits only for a held write lock.
+func (l *lock) RLock() {
+	l.mu.Lock()
+	if l.cond.L == nil {
+		l.cond.L = &l.mu
+	}
+	for l.writing {
+		l.cond.Wait()
+	}
+	l.readers++
+	l.mu.Unlock()
+}
+
+// RUnlock undoes a single RLock call.
+func (l *lock) RUnlock() {
+	l.mu.Lock()
+	if l.readers--; l.readers == 0 {
+		l.cond.Broadcast()
+	}
+	l.mu.Unlock()
+}
+
+// Lock locks for writing, it waits until there is no reader or writer.
+func (l *lock) Lock() {
+	l.mu.Lock()
+	if l.cond.L == nil {
+		l.cond.L = &l.mu
+	}
+	for l.writing || l.readers > 0 {
+		l.cond.Wait()
+	}
+	l.writing = true
+	l.mu.Unlock()
+}
+
+// Unlock unlocks for writing.
+func (l *lock) Unlock() {
+	l.mu.Lock()
+	l.writing = false
+	l.cond.Broadcast()
+	l.mu.Unlock()
 }
 
 // Open creates a new bolt driver by storage file path.
@@ -29,18 +90,22 @@ func Open(paths ...string) gkv.KV {
 		panic(err)
 	}
 	return &KV{
-		db:    db,
-		table: []byte(gkv.DefaultTableName),
+		conn: &conn{db: db},
+		path: [][]byte{[]byte(gkv.DefaultTableName)},
 	}
 }
 
 // DB returns the native DB of the adapter.
 func (kv KV) DB() interface{} {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
 	return kv.db
 }
 
 // Close releases all database resources.
 func (kv *KV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
 	return kv.db.Close()
 }
 
@@ -49,9 +114,47 @@ func (kv *KV) Register(table []byte) error {
 	if len(table) == 0 {
 		return gkv.ErrTableName
 	}
-	kv.table = table
+	if err := kv.create([][]byte{table}); err != nil {
+		return err
+	}
+	kv.path = [][]byte{table}
+	return nil
+}
+
+// Path returns a KV of the nested bucket addressed by names,
+// such as Path([]byte("tenant"), []byte("42"), []byte("orders")),
+// and creates the buckets if they don't already exist.
+// The returned KV shares the native DB, so closing either of them closes both.
+func (kv *KV) Path(names ...[]byte) (*KV, error) {
+	if len(names) == 0 {
+		return nil, gkv.ErrTableName
+	}
+	for _, name := range names {
+		if len(name) == 0 {
+			return nil, gkv.ErrTableName
+		}
+	}
+	if err := kv.create(names); err != nil {
+		return nil, err
+	}
+	return &KV{
+		conn: kv.conn,
+		path: names,
+	}, nil
+}
+
+// create creates the nested buckets of path if they don't already exist.
+func (kv *KV) create(path [][]byte) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
 	return kv.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(table)
+		b, err := tx.CreateBucketIfNotExists(path[0])
+		for _, name := range path[1:] {
+			if err != nil {
+				break
+			}
+			b, err = b.CreateBucketIfNotExists(name)
+		}
 		if err != nil {
 			return fmt.Errorf("CreateBucketIfNotExists error: %s",
 				err.Error())
@@ -60,17 +163,36 @@ func (kv *KV) Register(table []byte) error {
 	})
 }
 
+// bucket returns the bucket of the path in the transaction.
+func (kv *KV) bucket(tx *bolt.Tx) *bolt.Bucket {
+	b := tx.Bucket(kv.path[0])
+	for _, name := range kv.path[1:] {
+		if b == nil {
+			break
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
+
 // Put sets the value for a key.
 func (kv *KV) Put(key, value []byte) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
 	return kv.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(kv.table).Put(key, value)
+		return kv.bucket(tx).Put(key, value)
 	})
 }
 
-// Get retrieves the value for a key.
+// Get retrieves the value for a key, which is copied out of the memory map,
+// since Compact remaps the file.
 func (kv *KV) Get(key []byte) (value []byte) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
 	kv.db.View(func(tx *bolt.Tx) error {
-		value = tx.Bucket(kv.table).Get(key)
+		if v := kv.bucket(tx).Get(key); v != nil {
+			value = append([]byte{}, v...)
+		}
 		return nil
 	})
 	return
@@ -78,34 +200,204 @@ func (kv *KV) Get(key []byte) (value []byte) {
 
 // Delete deletes the given key from the database resources.
 func (kv *KV) Delete(key []byte) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
 	return kv.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(kv.table).Delete(key)
+		return kv.bucket(tx).Delete(key)
 	})
 }
 
-// Count returns the total number of all the keys.
-func (kv *KV) Count() (i int) {
+// Count returns the total number of all the keys, nested buckets excluded.
+func (kv *KV) Count() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.count()
+}
+
+// count returns the total number of all the keys, it must be called with
+// the read lock held.
+func (kv *KV) count() (i int) {
 	kv.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(kv.table).Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			i++
+		c := kv.bucket(tx).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v != nil {
+				i++
+			}
 		}
 		return nil
 	})
 	return
 }
 
-// Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
-	return kv.db.View(func(tx *bolt.Tx) error {
-		tx.Bucket(kv.table).ForEach(func(k, v []byte) error {
-			if k == nil || f(k, v) {
+// Iterator creates an iterator for iterating over all the keys,
+// nested buckets excluded, the keys and values passed to f are copies.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	err := kv.db.View(func(tx *bolt.Tx) error {
+		return kv.bucket(tx).ForEach(func(k, v []byte) error {
+			if v == nil {
 				return nil
 			}
-			return errors.New("stop")
+			return f(append([]byte{}, k...), append([]byte{}, v...))
+		})
+	})
+	if err == gkv.ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+// Walk walks all the keys of the bucket and its nested buckets recursively,
+// f is called with the path of nested bucket names relative to the bucket.
+// The walk stops at the first error of f, as Iterator does,
+// and the keys and values passed to f are copies.
+func (kv *KV) Walk(f func([][]byte, []byte, []byte) error) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	err := kv.db.View(func(tx *bolt.Tx) error {
+		return walk(kv.bucket(tx), nil, f)
+	})
+	if err == gkv.ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+// walk walks the bucket b at path.
+func walk(b *bolt.Bucket, path [][]byte, f func([][]byte, []byte, []byte) error) error {
+	return b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return f(path, append([]byte{}, k...), append([]byte{}, v...))
+		}
+		return walk(b.Bucket(k), append(path[:len(path):len(path)], append([]byte{}, k...)), f)
+	})
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.db.NoSync = !sync
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return kv.db.Sync()
+}
+
+// Compact reclaims the unused space of the storage,
+// it copies all the buckets into a fresh file which replaces the old one.
+// The other operations wait until it is done.
+func (kv *KV) Compact() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	path := kv.db.Path()
+	tmp := path + ".compact"
+	// a copy left by a failed compaction is started over.
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	dst, err := bolt.Open(tmp, 0600, nil)
+	if err != nil {
+		return err
+	}
+	err = kv.db.View(func(tx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				db, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return compact(db, b)
+			})
 		})
+	})
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = kv.db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		if e := kv.reopen(path); e != nil {
+			return e
+		}
+		return err
+	}
+	return kv.reopen(path)
+}
+
+// reopen replaces the closed DB by the one at path with the same options,
+// the closed one is kept if it fails, so the operations return its errors.
+func (kv *KV) reopen(path string) error {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return err
+	}
+	db.NoSync = kv.db.NoSync
+	kv.db = db
+	return nil
+}
+
+// compact copies the keys and nested buckets of src into dst.
+func compact(dst, src *bolt.Bucket) error {
+	dst.FillPercent = 1
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		b, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return compact(b, src.Bucket(k))
+	})
+}
+
+// Stats returns the statistics of the storage, the free space is
+// the free pages of the file, and the statistics of the bucket are
+// the backend-specific ones.
+func (kv *KV) Stats() gkv.Stats {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	dbStats := kv.db.Stats()
+	s := gkv.Stats{
+		Keys: kv.count(),
+		Size: gkv.DiskSize(kv.db.Path()),
+		Free: int64(dbStats.FreeAlloc),
+		Backend: map[string]interface{}{
+			"free_page_n":    dbStats.FreePageN,
+			"pending_page_n": dbStats.PendingPageN,
+			"freelist_inuse": dbStats.FreelistInuse,
+			"tx_n":           dbStats.TxN,
+		},
+	}
+	kv.db.View(func(tx *bolt.Tx) error {
+		if b := kv.bucket(tx); b != nil {
+			bs := b.Stats()
+			s.Backend["bucket_n"] = bs.BucketN
+			s.Backend["depth"] = bs.Depth
+			s.Backend["branch_page_n"] = bs.BranchPageN
+			s.Backend["leaf_page_n"] = bs.LeafPageN
+			s.Backend["leaf_alloc"] = bs.LeafAlloc
+			s.Backend["leaf_inuse"] = bs.LeafInuse
+		}
 		return nil
 	})
+	return s
 }
 
 func init() {
@@ -1,9 +1,16 @@
 package bolt
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/WindomZ/gkv"
 	"github.com/WindomZ/testify/assert"
+	"github.com/boltdb/bolt"
 )
 
 var demo *KV
@@ -42,12 +49,47 @@ func TestCount(t *testing.T) {
 
 func TestIterator(t *testing.T) {
 	cnt := 0
-	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) bool {
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
 		cnt++
-		return assert.Equal(t, demoKey, k) &&
-			assert.Equal(t, demoValue, v)
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
 	}))
 	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestPath(t *testing.T) {
+	_, err := demo.Path()
+	assert.Equal(t, gkv.ErrTableName, err)
+
+	orders, err := demo.Path(demoTable, []byte("tenant"), []byte("42"), []byte("orders"))
+	assert.NoError(t, err)
+	assert.NoError(t, orders.Put(demoKey, demoValue))
+	assert.Equal(t, demoValue, orders.Get(demoKey))
+	assert.Equal(t, 1, orders.Count())
+	assert.Equal(t, 1, demo.Count())
+
+	var paths []string
+	assert.NoError(t, demo.Walk(func(path [][]byte, k []byte, v []byte) error {
+		paths = append(paths, string(bytes.Join(append(path, k), []byte("/"))))
+		assert.Equal(t, demoValue, v)
+		return nil
+	}))
+	assert.Equal(t, []string{
+		string(demoKey),
+		"tenant/42/orders/" + string(demoKey),
+	}, paths)
+
+	assert.NoError(t, orders.Delete(demoKey))
+	assert.Equal(t, 0, orders.Count())
 }
 
 func TestDelete(t *testing.T) {
@@ -55,6 +97,93 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, demo.Count())
 }
 
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+
+	// a copy left by a failed compaction is started over.
+	path := demo.DB().(*bolt.DB).Path()
+	stale, err := bolt.Open(path+".compact", 0600, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, stale.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(demoTable)
+		return err
+	}))
+	assert.NoError(t, stale.Close())
+	assert.NoError(t, demo.Compact())
+
+	// the writes meanwhile are not lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				assert.NoError(t, demo.Put([]byte(fmt.Sprintf("compact%d-%d", i, j)), demoValue))
+			}
+		}(i)
+	}
+	assert.NoError(t, demo.Compact())
+	wg.Wait()
+	assert.Equal(t, 100, demo.Count())
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 25; j++ {
+			assert.NoError(t, demo.Delete([]byte(fmt.Sprintf("compact%d-%d", i, j))))
+		}
+	}
+}
+
+func TestCompactNested(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	value := demo.Get(demoKey)
+
+	// the calls in the callbacks don't wait for Compact waiting for them.
+	done, compacted := make(chan error, 1), make(chan error, 1)
+	go func() {
+		done <- demo.Iterator(func(k []byte, v []byte) error {
+			go func() { compacted <- demo.Compact() }()
+			time.Sleep(10 * time.Millisecond)
+			assert.Equal(t, v, demo.Get(k))
+			assert.Equal(t, 1, demo.Stats().Keys)
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the calls in the iterator are deadlocked")
+	}
+
+	// the values got before are not in the memory map of the old file.
+	assert.NoError(t, <-compacted)
+	assert.Equal(t, demoValue, value)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Contains(t, s.Backend, "depth")
+	assert.True(t, s.Free >= 0)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
 func TestClose(t *testing.T) {
 	assert.NoError(t, demo.Close())
 }
@@ -9,7 +9,8 @@ import (
 
 // KV is tidwall/buntdb adapter.
 type KV struct {
-	db *buntdb.DB
+	db   *buntdb.DB
+	path string
 }
 
 // Open creates a new buntdb driver by storage file path.
@@ -26,7 +27,8 @@ func Open(paths ...string) gkv.KV {
 		panic(err)
 	}
 	return &KV{
-		db: db,
+		db:   db,
+		path: path,
 	}
 }
 
@@ -87,13 +89,58 @@ func (kv *KV) Count() (i int) {
 }
 
 // Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
-	return kv.db.View(func(tx *buntdb.Tx) error {
-		err := tx.Ascend("", func(key, value string) bool {
-			return f(gkv.Stob(key), gkv.Stob(value))
-		})
-		return err
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	err := kv.db.View(func(tx *buntdb.Tx) (err error) {
+		if e := tx.Ascend("", func(key, value string) bool {
+			err = f(gkv.Stob(key), gkv.Stob(value))
+			return err == nil
+		}); e != nil {
+			return e
+		}
+		return
 	})
+	if err == gkv.ErrStopIteration {
+		return nil
+	}
+	return err
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	var config buntdb.Config
+	if kv.db.ReadConfig(&config) != nil {
+		return
+	}
+	if sync {
+		config.SyncPolicy = buntdb.Always
+	} else {
+		config.SyncPolicy = buntdb.EverySecond
+	}
+	kv.db.SetConfig(config)
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	if kv.path == ":memory:" {
+		return nil
+	}
+	return gkv.SyncFile(kv.path)
+}
+
+// Compact reclaims the unused space of the storage.
+func (kv *KV) Compact() error {
+	return kv.db.Shrink()
+}
+
+// Stats returns the statistics of the storage.
+func (kv *KV) Stats() gkv.Stats {
+	s := gkv.Stats{
+		Keys: kv.Count(),
+	}
+	if kv.path != ":memory:" {
+		s.Size = gkv.DiskSize(kv.path)
+	}
+	return s
 }
 
 func init() {
@@ -1,8 +1,10 @@
 package buntdb
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/WindomZ/gkv"
 	"github.com/WindomZ/testify/assert"
 )
 
@@ -42,12 +44,21 @@ func TestCount(t *testing.T) {
 
 func TestIterator(t *testing.T) {
 	cnt := 0
-	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) bool {
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
 		cnt++
-		return assert.Equal(t, demoKey, k) &&
-			assert.Equal(t, demoValue, v)
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
 	}))
 	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
 }
 
 func TestDelete(t *testing.T) {
@@ -55,6 +66,32 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, demo.Count())
 }
 
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
 func TestClose(t *testing.T) {
 	assert.NoError(t, demo.Close())
 }
@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/WindomZ/gkv"
+)
+
+// Options are the options of the cache layer.
+type Options struct {
+	// MaxBytes bounds the memory of the cached keys and values.
+	MaxBytes int
+	// Negative caches the keys which don't exist as well.
+	Negative bool
+}
+
+// DefaultOptions are the options used by Wrap.
+var DefaultOptions = Options{
+	MaxBytes: 32 << 20,
+}
+
+// Stats are the statistics of the cache.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int
+}
+
+// HitRate returns the ratio of the hits to all the lookups.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// entryOverhead is roughly the memory of an entry besides its key and value.
+const entryOverhead = 96
+
+// id identifies an entry in the cache by the table and the key.
+type id struct {
+	table, key string
+}
+
+// entry is a cached value, which is nil if the key doesn't exist.
+type entry struct {
+	key   id
+	value []byte
+	size  int
+}
+
+// KV is a layer caching the values read from any KV in a bounded LRU,
+// the writes through it invalidate the cache, and the next reads fill it.
+type KV struct {
+	gkv.KV
+	opts Options
+
+	mu    sync.Mutex
+	table string
+	ll    *list.List
+	items map[id]*list.Element
+	size  int
+	// gen changes on every write, so that a value read meanwhile isn't cached.
+	gen    uint64
+	hits   uint64
+	misses uint64
+}
+
+// Wrap returns kv with its values cached by DefaultOptions.
+func Wrap(kv gkv.KV) *KV {
+	return WrapOptions(DefaultOptions, kv)
+}
+
+// WrapOptions returns kv with its values cached by opts.
+func WrapOptions(opts Options, kv gkv.KV) *KV {
+	return &KV{
+		KV:    kv,
+		opts:  opts,
+		ll:    list.New(),
+		items: make(map[id]*list.Element),
+	}
+}
+
+// Unwrap returns the KV the layer is on.
+func (kv *KV) Unwrap() gkv.KV {
+	return kv.KV
+}
+
+// set caches the value of the key, it must be called with the lock held.
+func (kv *KV) set(k []byte, value []byte) {
+	ck := id{table: kv.table, key: string(k)}
+	if e, ok := kv.items[ck]; ok {
+		kv.remove(e)
+	}
+	if value == nil && !kv.opts.Negative {
+		return
+	}
+	size := len(ck.table) + len(ck.key) + len(value) + entryOverhead
+	if size > kv.opts.MaxBytes {
+		return
+	}
+	if value != nil {
+		value = append([]byte{}, value...)
+	}
+	kv.items[ck] = kv.ll.PushFront(&entry{key: ck, value: value, size: size})
+	kv.size += size
+	for kv.size > kv.opts.MaxBytes {
+		kv.remove(kv.ll.Back())
+	}
+}
+
+// invalidate drops the cached value of the key,
+// it must be called with the lock held.
+func (kv *KV) invalidate(k []byte) {
+	kv.gen++
+	if e, ok := kv.items[id{table: kv.table, key: string(k)}]; ok {
+		kv.remove(e)
+	}
+}
+
+// remove drops the entry, it must be called with the lock held.
+func (kv *KV) remove(e *list.Element) {
+	en := kv.ll.Remove(e).(*entry)
+	delete(kv.items, en.key)
+	kv.size -= en.size
+}
+
+// Purge drops all the cached values.
+func (kv *KV) Purge() {
+	kv.mu.Lock()
+	kv.gen++
+	kv.ll.Init()
+	kv.items = make(map[id]*list.Element)
+	kv.size = 0
+	kv.mu.Unlock()
+}
+
+// CacheStats returns the statistics of the cache.
+func (kv *KV) CacheStats() Stats {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return Stats{
+		Hits:    kv.hits,
+		Misses:  kv.misses,
+		Entries: kv.ll.Len(),
+		Bytes:   kv.size,
+	}
+}
+
+// Stats returns the statistics of the wrapped KV with the hit rate of the cache,
+// and the statistics of the cache as the backend-specific ones.
+func (kv *KV) Stats() gkv.Stats {
+	s := kv.KV.Stats()
+	cs := kv.CacheStats()
+	s.HitRate = cs.HitRate()
+	if s.Backend == nil {
+		s.Backend = make(map[string]interface{})
+	}
+	s.Backend["cache_hits"] = cs.Hits
+	s.Backend["cache_misses"] = cs.Misses
+	s.Backend["cache_entries"] = cs.Entries
+	s.Backend["cache_bytes"] = cs.Bytes
+	return s
+}
+
+// Close drops all the cached values and releases all database resources.
+func (kv *KV) Close() error {
+	kv.Purge()
+	return kv.KV.Close()
+}
+
+// Register initializes a new database if it doesn't already exist,
+// the values are cached by the table.
+func (kv *KV) Register(table []byte) error {
+	if err := kv.KV.Register(table); err != nil {
+		return err
+	}
+	kv.mu.Lock()
+	kv.table = string(table)
+	kv.mu.Unlock()
+	return nil
+}
+
+// Put sets the value for a key, and invalidates the cached value of it.
+// The value isn't cached by the write, since concurrent writes of the key
+// may reach the wrapped KV in another order than they return.
+func (kv *KV) Put(key, value []byte) error {
+	kv.mu.Lock()
+	kv.invalidate(key)
+	kv.mu.Unlock()
+	defer func() {
+		kv.mu.Lock()
+		kv.invalidate(key)
+		kv.mu.Unlock()
+	}()
+	return kv.KV.Put(key, value)
+}
+
+// Get retrieves the value for a key, from the cache if it is there.
+func (kv *KV) Get(k []byte) []byte {
+	kv.mu.Lock()
+	if e, ok := kv.items[id{table: kv.table, key: string(k)}]; ok {
+		kv.ll.MoveToFront(e)
+		kv.hits++
+		value := e.Value.(*entry).value
+		kv.mu.Unlock()
+		if value == nil {
+			return nil
+		}
+		return append([]byte{}, value...)
+	}
+	kv.misses++
+	gen := kv.gen
+	kv.mu.Unlock()
+
+	value := kv.KV.Get(k)
+	kv.mu.Lock()
+	if kv.gen == gen {
+		kv.set(k, value)
+	}
+	kv.mu.Unlock()
+	return value
+}
+
+// Delete deletes the given key from the database resources,
+// and invalidates the cached value of it.
+func (kv *KV) Delete(key []byte) error {
+	kv.mu.Lock()
+	kv.invalidate(key)
+	kv.mu.Unlock()
+	defer func() {
+		kv.mu.Lock()
+		kv.invalidate(key)
+		kv.mu.Unlock()
+	}()
+	return kv.KV.Delete(key)
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix,
+// it reads the wrapped KV.
+func (kv *KV) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	return gkv.PrefixOf(kv.KV, prefix, f)
+}
+
+// Write writes all the writes of the batch, atomically if the wrapped KV
+// is a Batcher, and invalidates the cached values of them.
+func (kv *KV) Write(b *gkv.Batch) error {
+	invalidate := func() {
+		kv.mu.Lock()
+		b.Replay(func(key, _ []byte) error {
+			kv.invalidate(key)
+			return nil
+		}, func(key []byte) error {
+			kv.invalidate(key)
+			return nil
+		})
+		kv.mu.Unlock()
+	}
+	invalidate()
+	defer invalidate()
+	return b.Apply(kv.KV)
+}
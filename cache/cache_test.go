@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	db := memory.Open()
+	kv := Wrap(db)
+
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Entries: 1, Bytes: 3 + 5 + entryOverhead}, kv.CacheStats())
+
+	// the cached value is not shared with the caller.
+	kv.Get([]byte("key"))[0] = 'V'
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+
+	// a write behind the cache is not seen until it is invalidated.
+	assert.NoError(t, db.Put([]byte("key"), []byte("behind")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	kv.Purge()
+	assert.Equal(t, []byte("behind"), kv.Get([]byte("key")))
+
+	assert.NoError(t, kv.Delete([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	s := kv.CacheStats()
+	assert.Equal(t, uint64(4), s.Misses)
+	assert.Equal(t, 0, s.Entries)
+	assert.InDelta(t, 4.0/8, s.HitRate(), 1e-9)
+
+	assert.NoError(t, kv.Register([]byte("table")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.NoError(t, kv.Close())
+	assert.Equal(t, 0, kv.CacheStats().Entries)
+}
+
+func TestNegative(t *testing.T) {
+	db := memory.Open()
+	kv := WrapOptions(Options{MaxBytes: 1 << 10, Negative: true}, db)
+
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Entries: 1, Bytes: 3 + entryOverhead}, kv.CacheStats())
+
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.NoError(t, kv.Delete([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("key")))
+	assert.Equal(t, Stats{Hits: 2, Misses: 3, Entries: 1, Bytes: 3 + entryOverhead}, kv.CacheStats())
+}
+
+func TestEvict(t *testing.T) {
+	kv := WrapOptions(Options{MaxBytes: 4 * (entryOverhead + 10)}, memory.Open())
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, kv.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")))
+		kv.Get([]byte(fmt.Sprintf("key%d", i)))
+	}
+	assert.Equal(t, 4, kv.CacheStats().Entries)
+	assert.True(t, kv.CacheStats().Bytes <= 4*(entryOverhead+10))
+
+	// the least recently used ones are evicted.
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key7")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key0")))
+	assert.Equal(t, Stats{Hits: 1, Misses: 9, Entries: 4, Bytes: 4 * (entryOverhead + 9)}, kv.CacheStats())
+
+	assert.NoError(t, kv.Put([]byte("big"), make([]byte, 1<<10)))
+	assert.Equal(t, 4, kv.CacheStats().Entries)
+	assert.Len(t, kv.Get([]byte("big")), 1<<10)
+}
+
+func TestWrite(t *testing.T) {
+	kv := Wrap(memory.Open())
+	assert.NoError(t, kv.Put([]byte("a1"), []byte("value1")))
+	assert.NoError(t, kv.Put([]byte("a2"), []byte("value2")))
+
+	b := new(gkv.Batch)
+	b.Put([]byte("a1"), []byte("value3"))
+	b.Delete([]byte("a2"))
+	assert.NoError(t, kv.Write(b))
+	assert.Equal(t, []byte("value3"), kv.Get([]byte("a1")))
+	assert.Nil(t, kv.Get([]byte("a2")))
+
+	var count int
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("a"), func(k, v []byte) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestConcurrent(t *testing.T) {
+	kv := Wrap(memory.Open())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := []byte(fmt.Sprintf("key%d", j%10))
+				if (i+j)%3 == 0 {
+					kv.Put(key, []byte(fmt.Sprint(j)))
+				} else {
+					kv.Get(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	kv.KV.Iterator(func(k, v []byte) error {
+		assert.Equal(t, v, kv.Get(k))
+		return nil
+	})
+}
+
+// slowKV delays the first write of every key, so that the concurrent writes
+// reach the wrapped KV in another order than they return.
+type slowKV struct {
+	gkv.KV
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (kv *slowKV) Put(key, value []byte) error {
+	kv.mu.Lock()
+	first := !kv.seen[string(key)]
+	kv.seen[string(key)] = true
+	err := kv.KV.Put(key, value)
+	kv.mu.Unlock()
+	if first {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	kv := Wrap(&slowKV{KV: memory.Open(), seen: make(map[string]bool)})
+	for i := 0; i < 10; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		var wg sync.WaitGroup
+		wg.Add(2)
+		started := make(chan struct{})
+		go func() {
+			defer wg.Done()
+			close(started)
+			kv.Put(key, []byte("A"))
+		}()
+		go func() {
+			defer wg.Done()
+			<-started
+			for kv.KV.Get(key) == nil {
+				runtime.Gosched()
+			}
+			kv.Put(key, []byte("B"))
+			kv.Get(key)
+		}()
+		wg.Wait()
+		assert.Equal(t, kv.KV.Get(key), kv.Get(key))
+	}
+}
+
+func TestStats(t *testing.T) {
+	kv := Wrap(memory.Open())
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	kv.Get([]byte("key"))
+	kv.Get([]byte("key"))
+	kv.Get([]byte("missing"))
+
+	s := kv.Stats()
+	assert.Equal(t, 1, s.Keys)
+	assert.Equal(t, int64(8), s.Size)
+	assert.InDelta(t, 1.0/3, s.HitRate, 1e-9)
+	assert.Equal(t, uint64(1), s.Backend["cache_hits"])
+	assert.Equal(t, 1, s.Backend["tables"])
+}
@@ -0,0 +1,156 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrCodec unknown codec error
+var ErrCodec = errors.New("unknown codec")
+
+// Codec marshals values into bytes and unmarshals them back.
+type Codec interface {
+	// ID identifies the codec in the header of encoded values.
+	ID() byte
+	// Name returns the name of the codec.
+	Name() string
+	// Marshal returns the bytes of v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal parses the bytes into v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// The IDs of the built-in codecs.
+const (
+	IDJSON byte = iota + 1
+	IDGob
+	IDMsgpack
+	IDProtobuf
+)
+
+var (
+	mu     sync.RWMutex
+	codecs = make(map[byte]Codec)
+)
+
+// Register makes a codec available by its ID.
+// Only the last one of an ID can take effect.
+func Register(c Codec) {
+	mu.Lock()
+	codecs[c.ID()] = c
+	mu.Unlock()
+}
+
+// Lookup returns the codec registered by the ID, or nil if there is none.
+func Lookup(id byte) Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+	return codecs[id]
+}
+
+// magic heads the header of encoded values, followed by the ID of the codec.
+var magic = []byte{0xc0, 0xde}
+
+// Encode marshals v by the codec into bytes with a header identifying it,
+// so that they can be decoded whichever codec is used later.
+func Encode(c Codec, v interface{}) ([]byte, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(append(make([]byte, 0, len(magic)+1+len(data)),
+		magic...), c.ID()), data...), nil
+}
+
+// Decode unmarshals the bytes into v by the codec identified in the header,
+// bytes without a header are unmarshaled by the fallback codec.
+func Decode(data []byte, v interface{}, fallback Codec) error {
+	if len(data) > len(magic) && bytes.HasPrefix(data, magic) {
+		c := Lookup(data[len(magic)])
+		if c == nil {
+			return ErrCodec
+		}
+		return c.Unmarshal(data[len(magic)+1:], v)
+	}
+	if fallback == nil {
+		return ErrCodec
+	}
+	return fallback.Unmarshal(data, v)
+}
+
+// Of is a Codec of the values of type T, which is a gkv.Codec[T],
+// such as the codecs of gkv.NewTyped.
+type Of[T any] struct {
+	Codec Codec
+}
+
+// For returns the Codec c of the values of type T, the values are encoded
+// with the header of Encode, and the values without it are decoded by c.
+func For[T any](c Codec) Of[T] {
+	return Of[T]{Codec: c}
+}
+
+// Encode returns the bytes of the value with the header.
+func (o Of[T]) Encode(v T) ([]byte, error) {
+	return Encode(o.Codec, v)
+}
+
+// Decode returns the value of the bytes by the codec identified in the header.
+func (o Of[T]) Decode(data []byte) (v T, err error) {
+	err = Decode(data, &v, o.Codec)
+	return
+}
+
+// JSON is the Codec of encoding/json.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte {
+	return IDJSON
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Gob is the Codec of encoding/gob.
+var Gob Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte {
+	return IDGob
+}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func init() {
+	Register(JSON)
+	Register(Gob)
+}
@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/WindomZ/testify/assert"
+)
+
+type demo struct {
+	Name  string
+	Value []byte
+}
+
+var demoValue = demo{Name: "name-名_1 2%3", Value: []byte{0x00, 0xff}}
+
+func TestRegister(t *testing.T) {
+	assert.Equal(t, JSON, Lookup(IDJSON))
+	assert.Equal(t, Gob, Lookup(IDGob))
+	assert.Nil(t, Lookup(0))
+}
+
+func TestEncode(t *testing.T) {
+	for _, c := range []Codec{JSON, Gob} {
+		data, err := Encode(c, demoValue)
+		assert.NoError(t, err)
+		assert.Equal(t, append(append([]byte{}, magic...), c.ID()), data[:3])
+
+		var v demo
+		assert.NoError(t, Decode(data, &v, nil), c.Name())
+		assert.Equal(t, demoValue, v, c.Name())
+	}
+}
+
+func TestFor(t *testing.T) {
+	c := For[demo](Gob)
+	data, err := c.Encode(demoValue)
+	assert.NoError(t, err)
+	v, err := c.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, demoValue, v)
+
+	// the values of other codecs and without the header are decoded as well.
+	data, err = Encode(JSON, demoValue)
+	assert.NoError(t, err)
+	v, err = c.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, demoValue, v)
+	v, err = For[demo](JSON).Decode([]byte(`{"Name":"legacy"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", v.Name)
+}
+
+func TestDecode(t *testing.T) {
+	var v demo
+	assert.NoError(t, Decode([]byte(`{"Name":"legacy"}`), &v, JSON))
+	assert.Equal(t, "legacy", v.Name)
+	assert.Equal(t, ErrCodec, Decode([]byte(`{}`), &v, nil))
+	assert.Equal(t, ErrCodec, Decode([]byte{0xc0, 0xde, 0xff}, &v, JSON))
+}
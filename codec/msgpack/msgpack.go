@@ -0,0 +1,31 @@
+package msgpack
+
+import (
+	"github.com/WindomZ/gkv/codec"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec is the codec.Codec of vmihailenco/msgpack.
+var Codec codec.Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte {
+	return codec.IDMsgpack
+}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+func init() {
+	codec.Register(Codec)
+}
@@ -0,0 +1,25 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/WindomZ/gkv/codec"
+	"github.com/WindomZ/testify/assert"
+)
+
+type demo struct {
+	Name  string
+	Value []byte
+}
+
+func TestCodec(t *testing.T) {
+	assert.Equal(t, Codec, codec.Lookup(codec.IDMsgpack))
+
+	value := demo{Name: "name-名_1 2%3", Value: []byte{0x00, 0xff}}
+	data, err := codec.Encode(Codec, value)
+	assert.NoError(t, err)
+
+	var v demo
+	assert.NoError(t, codec.Decode(data, &v, codec.JSON))
+	assert.Equal(t, value, v)
+}
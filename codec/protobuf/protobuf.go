@@ -0,0 +1,56 @@
+package protobuf
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/WindomZ/gkv/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrMessage is returned for values which are not protobuf messages.
+var ErrMessage = errors.New("not a protobuf message")
+
+// Codec is the codec.Codec of protobuf, values must be proto.Message,
+// or pointers to them to be unmarshaled into, as codec.For does.
+var Codec codec.Codec = protobufCodec{}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ID() byte {
+	return codec.IDProtobuf
+}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		// allocates the message of a pointer to a nil one.
+		p := reflect.ValueOf(v)
+		if p.Kind() != reflect.Ptr || p.IsNil() || p.Elem().Kind() != reflect.Ptr {
+			return ErrMessage
+		}
+		if p.Elem().IsNil() {
+			p.Elem().Set(reflect.New(p.Elem().Type().Elem()))
+		}
+		if m, ok = p.Elem().Interface().(proto.Message); !ok {
+			return ErrMessage
+		}
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func init() {
+	codec.Register(Codec)
+}
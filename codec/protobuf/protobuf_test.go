@@ -0,0 +1,30 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/WindomZ/gkv/codec"
+	"github.com/WindomZ/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	assert.Equal(t, Codec, codec.Lookup(codec.IDProtobuf))
+
+	data, err := codec.Encode(Codec, wrapperspb.String("value-值_7 8%9"))
+	assert.NoError(t, err)
+
+	var v wrapperspb.StringValue
+	assert.NoError(t, codec.Decode(data, &v, codec.JSON))
+	assert.Equal(t, "value-值_7 8%9", v.GetValue())
+
+	_, err = codec.Encode(Codec, "not a message")
+	assert.Equal(t, ErrMessage, err)
+
+	c := codec.For[*wrapperspb.StringValue](Codec)
+	data, err = c.Encode(wrapperspb.String("value"))
+	assert.NoError(t, err)
+	m, err := c.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", m.GetValue())
+}
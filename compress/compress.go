@@ -0,0 +1,237 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/WindomZ/gkv"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrAlgorithm unknown compression algorithm error
+	ErrAlgorithm = errors.New("unknown compression algorithm")
+	// ErrChecksum compressed value checksum mismatch error
+	ErrChecksum = errors.New("compressed value checksum mismatch")
+)
+
+// Algorithm is a compression algorithm of the values.
+type Algorithm byte
+
+// The compression algorithms, None stores the values as they are.
+const (
+	None Algorithm = iota
+	Snappy
+	Zstd
+	Gzip
+)
+
+// Options are the options of the compression layer.
+type Options struct {
+	// Algorithm compresses the values put.
+	Algorithm Algorithm
+	// Threshold is the size in bytes below which values are not compressed.
+	Threshold int
+	// Strict fails the values headed by magic whose checksum doesn't match,
+	// instead of reading them as the values put before the layer was added.
+	// Turn it on once all of those are rewritten, so corruption is caught.
+	Strict bool
+}
+
+// DefaultOptions are the options used by Wrap.
+var DefaultOptions = Options{
+	Algorithm: Snappy,
+	Threshold: 256,
+}
+
+// magic heads the compressed values, followed by the algorithm and
+// the big-endian CRC-32C of the algorithm and the data. Values without it,
+// or whose checksum doesn't match, are read as they are unless Strict,
+// so the values put before the layer was added still read.
+var magic = []byte{0xc0, 0x5a}
+
+// headerSize is the size of the header of the compressed values.
+const headerSize = 7
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum returns the checksum of the algorithm and the data.
+func checksum(a Algorithm, data []byte) uint32 {
+	return crc32.Update(crc32.Update(0, crcTable, []byte{byte(a)}), crcTable, data)
+}
+
+// header returns the header of the data compressed by a.
+func header(a Algorithm, data []byte) []byte {
+	return binary.BigEndian.AppendUint32(append(append([]byte{}, magic...), byte(a)), checksum(a, data))
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// KV is a layer compressing the values of any KV,
+// the keys are stored as they are.
+type KV struct {
+	gkv.KV
+	opts Options
+}
+
+// Wrap returns kv with its values compressed by DefaultOptions.
+func Wrap(kv gkv.KV) gkv.KV {
+	return WrapOptions(DefaultOptions, kv)
+}
+
+// WrapOptions returns kv with its values compressed by opts.
+func WrapOptions(opts Options, kv gkv.KV) gkv.KV {
+	if opts.Algorithm > Gzip {
+		panic(ErrAlgorithm)
+	}
+	return &KV{
+		KV:   kv,
+		opts: opts,
+	}
+}
+
+// Unwrap returns the KV the layer is on.
+func (kv *KV) Unwrap() gkv.KV {
+	return kv.KV
+}
+
+// compress returns the stored form of the value, which is left as it is
+// if it is below the threshold or doesn't shrink.
+func (kv *KV) compress(value []byte) ([]byte, error) {
+	if kv.opts.Algorithm != None && len(value) >= kv.opts.Threshold {
+		var data []byte
+		switch kv.opts.Algorithm {
+		case Snappy:
+			data = snappy.Encode(nil, value)
+		case Zstd:
+			data = zstdEncoder.EncodeAll(value, nil)
+		case Gzip:
+			var w bytes.Buffer
+			zw := gzip.NewWriter(&w)
+			if _, err := zw.Write(value); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+			data = w.Bytes()
+		}
+		if headerSize+len(data) < len(value) {
+			return append(header(kv.opts.Algorithm, data), data...), nil
+		}
+	}
+	if bytes.HasPrefix(value, magic) {
+		// keeps it from being read as compressed.
+		return append(header(None, value), value...), nil
+	}
+	return value, nil
+}
+
+// decompress returns the value of the stored form,
+// or the error of the algorithm if it is corrupted.
+func (kv *KV) decompress(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, magic) {
+		return value, nil
+	}
+	a := Algorithm(0xff)
+	var data []byte
+	if len(value) >= headerSize {
+		a, data = Algorithm(value[len(magic)]), value[headerSize:]
+	}
+	if a > Gzip || binary.BigEndian.Uint32(value[len(magic)+1:]) != checksum(a, data) {
+		if kv.opts.Strict {
+			return nil, ErrChecksum
+		}
+		return value, nil
+	}
+	switch a {
+	case Snappy:
+		return snappy.Decode(nil, data)
+	case Zstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return data, nil
+}
+
+// Put sets the compressed value for a key.
+func (kv *KV) Put(key, value []byte) error {
+	v, err := kv.compress(value)
+	if err != nil {
+		return err
+	}
+	return kv.KV.Put(key, v)
+}
+
+// Get retrieves the decompressed value for a key,
+// it returns nil if the value fails to decompress, use Load to get the error.
+func (kv *KV) Get(key []byte) []byte {
+	value, _ := kv.Load(key)
+	return value
+}
+
+// Load retrieves the decompressed value for a key,
+// or the error if the value fails to decompress.
+func (kv *KV) Load(key []byte) ([]byte, error) {
+	if v := kv.KV.Get(key); v != nil {
+		return kv.decompress(v)
+	}
+	return nil, nil
+}
+
+// Iterator creates an iterator for iterating over all the keys
+// with the decompressed values, it stops at the first value failing to decompress.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	return kv.KV.Iterator(func(k, v []byte) error {
+		value, err := kv.decompress(v)
+		if err != nil {
+			return err
+		}
+		return f(k, value)
+	})
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix
+// with the decompressed values, it stops at the first value failing to decompress.
+func (kv *KV) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	return gkv.PrefixOf(kv.KV, prefix, func(k, v []byte) error {
+		value, err := kv.decompress(v)
+		if err != nil {
+			return err
+		}
+		return f(k, value)
+	})
+}
+
+// Write writes all the writes of the batch with the compressed values,
+// atomically if the wrapped KV is a Batcher.
+func (kv *KV) Write(b *gkv.Batch) error {
+	batch := new(gkv.Batch)
+	if err := b.Replay(func(key, value []byte) error {
+		v, err := kv.compress(value)
+		if err == nil {
+			batch.Put(key, v)
+		}
+		return err
+	}, func(key []byte) error {
+		batch.Delete(key)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return batch.Apply(kv.KV)
+}
@@ -0,0 +1,116 @@
+package compress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demoValue = []byte(strings.Repeat(`{"name":"value-值_7 8%9","age":42},`, 32))
+
+func TestWrap(t *testing.T) {
+	for _, algorithm := range []Algorithm{None, Snappy, Zstd, Gzip} {
+		db := memory.Open()
+		kv := WrapOptions(Options{Algorithm: algorithm, Threshold: 64}, db)
+		assert.Equal(t, "memory", gkv.DriverName(kv))
+
+		assert.NoError(t, kv.Put([]byte("key"), demoValue))
+		assert.Equal(t, demoValue, kv.Get([]byte("key")))
+		if algorithm == None {
+			assert.Equal(t, demoValue, db.Get([]byte("key")))
+		} else {
+			assert.True(t, len(db.Get([]byte("key"))) < len(demoValue)/4)
+		}
+
+		assert.NoError(t, kv.Put([]byte("small"), []byte("value")))
+		assert.Equal(t, []byte("value"), db.Get([]byte("small")))
+		assert.Equal(t, []byte("value"), kv.Get([]byte("small")))
+
+		assert.Nil(t, kv.Get([]byte("missing")))
+	}
+
+	assert.Panics(t, func() { WrapOptions(Options{Algorithm: Gzip + 1}, memory.Open()) })
+}
+
+func TestLegacy(t *testing.T) {
+	legacy := [][]byte{
+		demoValue,
+		append(append([]byte{}, magic...), 0xff),
+		append(append([]byte{}, magic...), byte(None), 'l', 'e', 'g', 'a', 'c', 'y'),
+		append(append([]byte{}, magic...), byte(Snappy), 0xff, 0xff, 0xff, 0xff, 0xff),
+	}
+	db := memory.Open()
+	for i, value := range legacy {
+		assert.NoError(t, db.Put([]byte{byte(i)}, value))
+	}
+
+	kv := Wrap(db)
+	for i, value := range legacy {
+		assert.Equal(t, value, kv.Get([]byte{byte(i)}))
+	}
+	assert.NoError(t, kv.Iterator(func(k, v []byte) error {
+		assert.Equal(t, legacy[k[0]], v)
+		return nil
+	}))
+
+	value := append(append([]byte{}, magic...), byte(Snappy), 'a')
+	assert.NoError(t, kv.Put([]byte("magic"), value))
+	assert.Equal(t, value, kv.Get([]byte("magic")))
+}
+
+func TestCorrupted(t *testing.T) {
+	db := memory.Open()
+	kv := WrapOptions(Options{Algorithm: Snappy, Strict: true}, db)
+	for _, a := range []Algorithm{Snappy, Zstd, Gzip} {
+		data := []byte{0xff, 0xff}
+		assert.NoError(t, db.Put([]byte("corrupted"), append(header(a, data), data...)))
+		assert.Nil(t, kv.Get([]byte("corrupted")))
+		_, err := kv.(*KV).Load([]byte("corrupted"))
+		assert.Error(t, err)
+		assert.Error(t, kv.Iterator(func(k, v []byte) error {
+			return nil
+		}))
+		assert.Error(t, gkv.PrefixOf(kv, []byte("corrupted"), func(k, v []byte) error {
+			return nil
+		}))
+	}
+
+	assert.NoError(t, kv.Put([]byte("corrupted"), demoValue))
+	v := db.Get([]byte("corrupted"))
+	v[len(v)-1]++
+	assert.NoError(t, db.Put([]byte("corrupted"), v))
+	_, err := kv.(*KV).Load([]byte("corrupted"))
+	assert.Equal(t, ErrChecksum, err)
+	assert.Equal(t, v, Wrap(db).Get([]byte("corrupted")))
+}
+
+func TestIterator(t *testing.T) {
+	kv := Wrap(memory.Open())
+	b := new(gkv.Batch)
+	b.Put([]byte("a1"), demoValue)
+	b.Put([]byte("a2"), []byte("value"))
+	b.Put([]byte("b1"), demoValue)
+	b.Delete([]byte("b1"))
+	assert.NoError(t, kv.(gkv.Batcher).Write(b))
+	assert.Equal(t, 2, kv.Count())
+
+	var values [][]byte
+	assert.NoError(t, kv.Iterator(func(k, v []byte) error {
+		values = append(values, v)
+		return nil
+	}))
+	assert.Equal(t, [][]byte{demoValue, []byte("value")}, values)
+
+	assert.NoError(t, kv.Put([]byte("b2"), demoValue))
+	values = nil
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("b"), func(k, v []byte) error {
+		assert.True(t, bytes.HasPrefix(k, []byte("b")))
+		values = append(values, v)
+		return nil
+	}))
+	assert.Equal(t, [][]byte{demoValue}, values)
+}
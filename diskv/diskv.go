@@ -1,21 +1,83 @@
 package diskv
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 
 	"github.com/WindomZ/gkv"
 	"github.com/peterbourgon/diskv"
 )
 
+// Options are params for creating a diskv driver.
+type Options struct {
+	// Transform maps a file name to the directories of the file.
+	Transform func(string) []string
+	// CacheSizeMax is the max size of the in-memory cache in bytes.
+	CacheSizeMax uint64
+	// Migrate converts the files of the legacy layout, which were named by
+	// the raw keys in the base directory, into encoded keys on open.
+	// Every regular file in the base directory, but the hidden ones,
+	// is taken as a legacy key and removed once it is converted,
+	// so the base directory must only hold the store.
+	Migrate bool
+}
+
+// DefaultOptions sets a list of recommended options for good performance.
+var DefaultOptions = Options{
+	Transform:    HashTransform,
+	CacheSizeMax: 1024 * 1024,
+}
+
+// HashTransform shards the files into 65536 directories by the hash of names,
+// so that there are not too many files in one directory.
+func HashTransform(name string) []string {
+	sum := sha1.Sum([]byte(name))
+	h := hex.EncodeToString(sum[:2])
+	return []string{h[:2], h[2:]}
+}
+
+// FlatTransform puts all the files into the base directory.
+func FlatTransform(string) []string {
+	return []string{}
+}
+
+// maxNameLen is the max length of the file name of an encoded key,
+// longer keys are named by their hash.
+const maxNameLen = 200
+
+// hashPrefix prefixes the file names of the keys named by their hash,
+// which is not in the alphabet of base64 URL encoding.
+const hashPrefix = "~"
+
+// layoutFile marks a base directory whose files are named by encoded keys,
+// the files of a base directory without it are of the legacy layout.
+const layoutFile = ".gkv-diskv"
+
 // KV is peterbourgon/diskv adapter.
 type KV struct {
-	db *diskv.Diskv
+	db   *diskv.Diskv
+	path string
+	sync atomic.Bool
 }
 
 // Open creates a new diskv driver by storage file path.
 // paths are storage file paths.
 func Open(paths ...string) gkv.KV {
+	return OpenOptions(DefaultOptions, paths...)
+}
+
+// OpenOptions creates a new diskv driver by options and storage file path.
+// paths are storage file paths.
+func OpenOptions(opts Options, paths ...string) gkv.KV {
 	var path string
 	if len(paths) != 0 {
 		path = paths[0]
@@ -34,18 +96,65 @@ func Open(paths ...string) gkv.KV {
 		path = filepath.Dir(path)
 	}
 
+	if opts.Transform == nil {
+		opts.Transform = FlatTransform
+	}
 	db := diskv.New(diskv.Options{
 		BasePath:     path,
-		Transform:    func(s string) []string { return []string{} },
-		CacheSizeMax: 1024 * 1024,
+		Transform:    opts.Transform,
+		CacheSizeMax: opts.CacheSizeMax,
 	})
-	return &KV{
-		db: db,
+	kv := &KV{
+		db:   db,
+		path: path,
+	}
+	if opts.Migrate {
+		if err = kv.migrate(opts.Transform); err != nil {
+			panic(err)
+		}
+	}
+	return kv
+}
+
+// migrate converts the files of the legacy layout,
+// which were named by the raw keys in the base directory, into encoded keys,
+// the layout file marks it done, so the encoded keys are not converted again.
+func (kv *KV) migrate(transform func(string) []string) error {
+	layout := filepath.Join(kv.path, layoutFile)
+	if _, err := os.Stat(layout); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	entries, err := os.ReadDir(kv.path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		old := e.Name()
+		if strings.HasPrefix(old, ".") {
+			continue
+		}
+		if name, _ := encode([]byte(old)); name == old && len(transform(name)) == 0 {
+			continue
+		}
+		value, err := os.ReadFile(filepath.Join(kv.path, old))
+		if err != nil {
+			return err
+		}
+		if err = kv.Put([]byte(old), value); err != nil {
+			return err
+		}
+		if err = os.Remove(filepath.Join(kv.path, old)); err != nil {
+			return err
+		}
 	}
+	return os.WriteFile(layout, nil, 0644)
 }
 
 // DB returns the native DB of the adapter.
-func (kv KV) DB() interface{} {
+func (kv *KV) DB() interface{} {
 	return kv.db
 }
 
@@ -59,48 +168,137 @@ func (kv *KV) Register(table []byte) error {
 	return nil
 }
 
+// encode returns the file name of the key, any byte sequence is a valid key.
+// Short keys are named by their base64 URL encoding,
+// long keys are named by their hash and stored at the head of the file.
+func encode(key []byte) (name string, long bool) {
+	name = base64.RawURLEncoding.EncodeToString(key)
+	if len(name) <= maxNameLen {
+		return name, false
+	}
+	sum := sha256.Sum256(key)
+	return hashPrefix + hex.EncodeToString(sum[:]), true
+}
+
+// decode returns the key of the file name, long is true if the key is
+// stored at the head of the file, ok is false if it is not an encoded key.
+func decode(name string) (key []byte, long, ok bool) {
+	if strings.HasPrefix(name, hashPrefix) {
+		return nil, true, true
+	}
+	key, err := base64.RawURLEncoding.DecodeString(name)
+	return key, false, err == nil
+}
+
+// split splits the data of a long key file into the key and value.
+func split(data []byte) (key, value []byte, err error) {
+	n, i := binary.Uvarint(data)
+	if i <= 0 || uint64(len(data)-i) < n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return data[i : i+int(n)], data[i+int(n):], nil
+}
+
 // Put sets the value for a key.
 func (kv *KV) Put(key, value []byte) error {
-	return kv.db.Write(gkv.Btos(key), value)
+	name, long := encode(key)
+	var r io.Reader = bytes.NewReader(value)
+	if long {
+		head := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(key))
+		head = append(head[:binary.PutUvarint(head, uint64(len(key)))], key...)
+		r = io.MultiReader(bytes.NewReader(head), r)
+	}
+	return kv.db.WriteStream(name, r, kv.sync.Load())
 }
 
 // Get retrieves the value for a key.
 func (kv *KV) Get(key []byte) (value []byte) {
-	value, _ = kv.db.Read(gkv.Btos(key))
+	name, long := encode(key)
+	value, err := kv.db.Read(name)
+	if err != nil {
+		return nil
+	}
+	if long {
+		k, v, err := split(value)
+		if err != nil || !bytes.Equal(k, key) {
+			return nil
+		}
+		value = v
+	}
 	return
 }
 
 // Delete deletes the given key from the database resources.
 func (kv *KV) Delete(key []byte) error {
-	return kv.db.Erase(gkv.Btos(key))
+	name, _ := encode(key)
+	return kv.db.Erase(name)
 }
 
 // Count returns the total number of all the keys.
 func (kv *KV) Count() (i int) {
-	for k := range kv.db.Keys(nil) {
-		_, err := kv.db.Read(k)
-		if err != nil {
-			break
+	for name := range kv.db.Keys(nil) {
+		if _, _, ok := decode(name); ok {
+			i++
 		}
-		i++
 	}
 	return
 }
 
-// Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
-	for k := range kv.db.Keys(nil) {
-		v, err := kv.db.Read(k)
-		if err != nil {
-			return err
+// Iterator creates an iterator for iterating over all the keys,
+// files not named by an encoded key are skipped.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	cancel := make(chan struct{})
+	defer close(cancel)
+	for name := range kv.db.Keys(cancel) {
+		key, long, ok := decode(name)
+		if !ok {
+			continue
 		}
-		if !f(gkv.Stob(k), v) {
-			break
+		value, err := kv.db.Read(name)
+		if err == nil && long {
+			key, value, err = split(value)
+		}
+		if err == nil {
+			err = f(key, value)
+		}
+		if err == gkv.ErrStopIteration {
+			return nil
+		} else if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	kv.sync.Store(sync)
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	return filepath.Walk(kv.path, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return gkv.SyncFile(path)
+	})
+}
+
+// Compact reclaims the unused space of the storage,
+// diskv removes the files of deleted keys at once, so there is nothing to do.
+func (kv *KV) Compact() error {
+	return nil
+}
+
+// Stats returns the statistics of the storage.
+func (kv *KV) Stats() gkv.Stats {
+	return gkv.Stats{
+		Keys: kv.Count(),
+		Size: gkv.DiskSize(kv.path),
+	}
+}
+
 func init() {
 	gkv.Register(Open)
 }
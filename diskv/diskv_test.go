@@ -1,8 +1,13 @@
 package diskv
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/WindomZ/gkv"
 	"github.com/WindomZ/testify/assert"
 )
 
@@ -28,6 +33,50 @@ func TestRegister(t *testing.T) {
 	assert.NoError(t, demo.Register(demoTable))
 }
 
+func TestMigrate(t *testing.T) {
+	path := "../data/test-diskv-legacy"
+	assert.NoError(t, os.RemoveAll(path))
+	assert.NoError(t, os.MkdirAll(path, 0755))
+	for _, key := range []string{string(demoKey), "user"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(path, key), demoValue, 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(path, ".gitignore"), demoValue, 0644))
+
+	// the files are not migrated unless it is opted in.
+	kv := Open(path)
+	assert.Nil(t, kv.Get(demoKey))
+	assert.NoError(t, kv.Close())
+	for _, name := range []string{string(demoKey), "user", ".gitignore"} {
+		_, err := os.Stat(filepath.Join(path, name))
+		assert.NoError(t, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		kv := OpenOptions(Options{Transform: HashTransform, Migrate: true}, path)
+		assert.Equal(t, 2, kv.Count())
+		assert.Equal(t, demoValue, kv.Get(demoKey))
+		assert.Equal(t, demoValue, kv.Get([]byte("user")))
+		found := make(map[string]bool)
+		assert.NoError(t, kv.Iterator(func(k []byte, v []byte) error {
+			found[string(k)] = true
+			return nil
+		}))
+		assert.Equal(t, map[string]bool{string(demoKey): true, "user": true}, found)
+		assert.NoError(t, kv.Close())
+	}
+	_, err := os.Stat(filepath.Join(path, ".gitignore"))
+	assert.NoError(t, err)
+
+	// the files next to a store file are left alone.
+	other := filepath.Join(path, "other.db")
+	assert.NoError(t, os.WriteFile(other, demoValue, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(path, "mine.db"), nil, 0644))
+	assert.NoError(t, Open(filepath.Join(path, "mine.db")).Close())
+	data, err := os.ReadFile(other)
+	assert.NoError(t, err)
+	assert.Equal(t, demoValue, data)
+}
+
 func TestPut(t *testing.T) {
 	assert.NoError(t, demo.Put(demoKey, demoValue))
 }
@@ -42,12 +91,48 @@ func TestCount(t *testing.T) {
 
 func TestIterator(t *testing.T) {
 	cnt := 0
-	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) bool {
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
 		cnt++
-		return assert.Equal(t, demoKey, k) &&
-			assert.Equal(t, demoValue, v)
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
 	}))
 	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestKeys(t *testing.T) {
+	keys := [][]byte{
+		[]byte("../key/键"),
+		{0x00, 0xff, '/', 0x00},
+		bytes.Repeat([]byte("long-key/"), 100),
+	}
+	for _, key := range keys {
+		assert.NoError(t, demo.Put(key, demoValue))
+		assert.Equal(t, demoValue, demo.Get(key))
+	}
+	assert.Equal(t, 1+len(keys), demo.Count())
+
+	found := make(map[string]bool)
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		found[string(k)] = true
+		assert.Equal(t, demoValue, v)
+		return nil
+	}))
+	assert.True(t, found[string(demoKey)])
+	for _, key := range keys {
+		assert.True(t, found[string(key)])
+		assert.NoError(t, demo.Delete(key))
+		assert.Nil(t, demo.Get(key))
+	}
+	assert.Equal(t, 1, demo.Count())
 }
 
 func TestDelete(t *testing.T) {
@@ -55,6 +140,32 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, demo.Count())
 }
 
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
 func TestClose(t *testing.T) {
 	assert.NoError(t, demo.Close())
 }
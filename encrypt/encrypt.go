@@ -0,0 +1,352 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/WindomZ/gkv"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// ErrCipher unknown cipher error
+	ErrCipher = errors.New("unknown cipher")
+	// ErrKeySize illegal encryption key size error
+	ErrKeySize = errors.New("illegal encryption key size")
+	// ErrKeyID unknown encryption key id error
+	ErrKeyID = errors.New("unknown encryption key id")
+	// ErrDecrypt message authentication failed error
+	ErrDecrypt = errors.New("message authentication failed")
+)
+
+// Cipher is an AEAD cipher of the records.
+type Cipher byte
+
+// The AEAD ciphers, both of them take 32-byte keys.
+const (
+	AESGCM Cipher = iota
+	XChaCha20Poly1305
+)
+
+// Options are the options of the encryption layer.
+type Options struct {
+	// Cipher encrypts the records put.
+	Cipher Cipher
+	// Keys are the 32-byte keys by their IDs, the key of KeyID encrypts
+	// the records put, the others decrypt the records put before by Cipher.
+	Keys map[uint32][]byte
+	// KeyID is the ID of the key encrypting the records put.
+	KeyID uint32
+	// EncryptKeys encrypts the keys deterministically as well, so that Get
+	// still works, but the keys are no longer iterated in order and Prefix
+	// iterates over all of them.
+	EncryptKeys bool
+}
+
+// headerSize is the size of the header of encrypted keys and values,
+// which is the cipher followed by the big-endian key ID.
+const headerSize = 5
+
+// suite is the cipher of a key ID.
+type suite struct {
+	cipher Cipher
+	id     uint32
+	aead   cipher.AEAD
+	// mac derives the nonces of the encrypted keys.
+	mac []byte
+}
+
+// header returns the header of the records encrypted by s.
+func (s *suite) header() []byte {
+	return binary.BigEndian.AppendUint32([]byte{byte(s.cipher)}, s.id)
+}
+
+// seal encrypts plain with the nonce, or a random one if it is nil.
+func (s *suite) seal(plain, nonce, data []byte) []byte {
+	buf := s.header()
+	if nonce == nil {
+		nonce = make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			panic(err)
+		}
+	}
+	buf = append(buf, nonce...)
+	return s.aead.Seal(buf, nonce, plain, append(buf[:headerSize:headerSize], data...))
+}
+
+// nonce returns the nonce deriving from the key,
+// which encrypts the same key into the same bytes.
+func (s *suite) nonce(key []byte) []byte {
+	h := hmac.New(sha256.New, s.mac)
+	h.Write(key)
+	return h.Sum(nil)[:s.aead.NonceSize()]
+}
+
+// KV is a layer encrypting the values, and optionally the keys, of any KV
+// by AEAD ciphers. Every record is headed by the cipher and the ID of
+// the key encrypting it, so that the keys can be rotated by Rekey.
+type KV struct {
+	gkv.KV
+	opts   Options
+	suite  *suite
+	suites []*suite
+}
+
+// Wrap returns kv with its records encrypted by opts,
+// it panics if the keys are illegal.
+func Wrap(opts Options, kv gkv.KV) *KV {
+	if opts.Cipher > XChaCha20Poly1305 {
+		panic(ErrCipher)
+	}
+	w := &KV{
+		KV:   kv,
+		opts: opts,
+	}
+	ids := make([]uint32, 0, len(opts.Keys))
+	for id := range opts.Keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		s, err := newSuite(opts.Cipher, id, opts.Keys[id])
+		if err != nil {
+			panic(err)
+		}
+		if id == opts.KeyID {
+			w.suite = s
+		}
+		w.suites = append(w.suites, s)
+	}
+	if w.suite == nil {
+		panic(ErrKeyID)
+	}
+	return w
+}
+
+// Unwrap returns the KV the layer is on.
+func (kv *KV) Unwrap() gkv.KV {
+	return kv.KV
+}
+
+// newSuite returns the suite of the cipher and the key.
+func newSuite(c Cipher, id uint32, key []byte) (*suite, error) {
+	if len(key) != 32 {
+		return nil, ErrKeySize
+	}
+	s := &suite{
+		cipher: c,
+		id:     id,
+	}
+	var err error
+	switch c {
+	case AESGCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			s.aead, err = cipher.NewGCM(block)
+		}
+	case XChaCha20Poly1305:
+		s.aead, err = chacha20poly1305.NewX(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("gkv/encrypt key nonce"))
+	s.mac = h.Sum(nil)
+	return s, nil
+}
+
+// open decrypts data encrypted by seal, data is authenticated with it.
+func (kv *KV) open(sealed, data []byte) ([]byte, *suite, error) {
+	if len(sealed) < headerSize {
+		return nil, nil, ErrDecrypt
+	}
+	var s *suite
+	for _, v := range kv.suites {
+		if bytes.Equal(sealed[:headerSize], v.header()) {
+			s = v
+			break
+		}
+	}
+	if s == nil {
+		return nil, nil, ErrKeyID
+	}
+	n := headerSize + s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, nil, ErrDecrypt
+	}
+	aad := append(append(make([]byte, 0, headerSize+len(data)), sealed[:headerSize]...), data...)
+	plain, err := s.aead.Open(nil, sealed[headerSize:n], sealed[n:], aad)
+	if err != nil {
+		return nil, nil, ErrDecrypt
+	}
+	return plain, s, nil
+}
+
+// storedKey returns the key stored for the key by s.
+func (kv *KV) storedKey(s *suite, key []byte) []byte {
+	if !kv.opts.EncryptKeys {
+		return key
+	}
+	return s.seal(key, s.nonce(key), nil)
+}
+
+// storedKeys returns the keys which may be stored for the key,
+// the one of the current key ID goes first.
+func (kv *KV) storedKeys(key []byte) [][]byte {
+	keys := [][]byte{kv.storedKey(kv.suite, key)}
+	if kv.opts.EncryptKeys {
+		for _, s := range kv.suites {
+			if s != kv.suite {
+				keys = append(keys, kv.storedKey(s, key))
+			}
+		}
+	}
+	return keys
+}
+
+// put adds the writes putting the encrypted value for the key to the batch,
+// which drop the records of the key put before rotating as well.
+func (kv *KV) put(b *gkv.Batch, key, value []byte) {
+	keys := kv.storedKeys(key)
+	b.Put(keys[0], kv.suite.seal(value, nil, key))
+	kv.dropStale(b, keys[1:])
+}
+
+// delete adds the writes deleting the key to the batch.
+func (kv *KV) delete(b *gkv.Batch, key []byte) {
+	keys := kv.storedKeys(key)
+	b.Delete(keys[0])
+	kv.dropStale(b, keys[1:])
+}
+
+// dropStale adds the deletes of the stored keys of the other key IDs
+// to the batch, only for those which exist, so that no needless write is done.
+func (kv *KV) dropStale(b *gkv.Batch, keys [][]byte) {
+	for _, k := range keys {
+		if kv.KV.Get(k) != nil {
+			b.Delete(k)
+		}
+	}
+}
+
+// record returns the decrypted key and value of a stored record.
+func (kv *KV) record(k, v []byte) (key, value []byte, rekey bool, err error) {
+	key = k
+	if kv.opts.EncryptKeys {
+		var s *suite
+		if key, s, err = kv.open(k, nil); err != nil {
+			return
+		}
+		rekey = s != kv.suite
+	}
+	value, s, err := kv.open(v, key)
+	return key, value, rekey || s != kv.suite, err
+}
+
+// Put sets the encrypted value for a key, and drops the records of the key
+// put by the other key IDs, atomically if the wrapped KV is a Batcher.
+func (kv *KV) Put(key, value []byte) error {
+	b := new(gkv.Batch)
+	kv.put(b, key, value)
+	return b.Apply(kv.KV)
+}
+
+// Get retrieves the decrypted value for a key,
+// it returns nil if the value fails to decrypt.
+func (kv *KV) Get(key []byte) []byte {
+	for _, k := range kv.storedKeys(key) {
+		if v := kv.KV.Get(k); v != nil {
+			value, _, _ := kv.open(v, key)
+			return value
+		}
+	}
+	return nil
+}
+
+// Delete deletes the given key from the database resources,
+// atomically if the wrapped KV is a Batcher.
+func (kv *KV) Delete(key []byte) error {
+	b := new(gkv.Batch)
+	kv.delete(b, key)
+	return b.Apply(kv.KV)
+}
+
+// Iterator creates an iterator for iterating over all the keys
+// with the decrypted values, it stops at the first record failing to decrypt.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	return kv.KV.Iterator(func(k, v []byte) error {
+		key, value, _, err := kv.record(k, v)
+		if err != nil {
+			return err
+		}
+		return f(key, value)
+	})
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix
+// with the decrypted values.
+func (kv *KV) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	if kv.opts.EncryptKeys {
+		return kv.Iterator(func(k, v []byte) error {
+			if bytes.HasPrefix(k, prefix) {
+				return f(k, v)
+			}
+			return nil
+		})
+	}
+	return gkv.PrefixOf(kv.KV, prefix, func(k, v []byte) error {
+		value, _, err := kv.open(v, k)
+		if err != nil {
+			return err
+		}
+		return f(k, value)
+	})
+}
+
+// Write writes all the writes of the batch encrypted,
+// atomically if the wrapped KV is a Batcher.
+func (kv *KV) Write(b *gkv.Batch) error {
+	batch := new(gkv.Batch)
+	if err := b.Replay(func(key, value []byte) error {
+		kv.put(batch, key, value)
+		return nil
+	}, func(key []byte) error {
+		kv.delete(batch, key)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return batch.Apply(kv.KV)
+}
+
+// Rekey re-encrypts all the records not encrypted by the key of KeyID, so that the other keys can be dropped afterwards.
+// The rewrites are collected before they are written as a batch,
+// atomically if the wrapped KV is a Batcher.
+func (kv *KV) Rekey() error {
+	batch := new(gkv.Batch)
+	if err := kv.KV.Iterator(func(k, v []byte) error {
+		// the adapters may reuse the buffers of k and v after the callback.
+		k, v = append([]byte{}, k...), append([]byte{}, v...)
+		key, value, rekey, err := kv.record(k, v)
+		if err != nil || !rekey {
+			return err
+		}
+		stored := kv.storedKey(kv.suite, key)
+		if !bytes.Equal(stored, k) {
+			batch.Delete(k)
+		}
+		batch.Put(stored, kv.suite.seal(value, nil, key))
+		return nil
+	}); err != nil {
+		return err
+	}
+	return batch.Apply(kv.KV)
+}
@@ -0,0 +1,151 @@
+package encrypt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/leveldb"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+var (
+	demoKey1 = bytes.Repeat([]byte{1}, 32)
+	demoKey2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestWrap(t *testing.T) {
+	for _, c := range []Cipher{AESGCM, XChaCha20Poly1305} {
+		db := memory.Open()
+		kv := Wrap(Options{Cipher: c, Keys: map[uint32][]byte{1: demoKey1}, KeyID: 1}, db)
+		assert.Equal(t, "memory", gkv.DriverName(gkv.Wrap(kv)))
+
+		assert.NoError(t, kv.Put([]byte("key"), []byte("token")))
+		assert.Equal(t, []byte("token"), kv.Get([]byte("key")))
+		assert.Nil(t, kv.Get([]byte("missing")))
+
+		stored := db.Get([]byte("key"))
+		assert.False(t, bytes.Contains(stored, []byte("token")))
+		assert.Equal(t, []byte{byte(c), 0, 0, 0, 1}, stored[:headerSize])
+
+		// a value moved to another key fails to authenticate.
+		assert.NoError(t, db.Put([]byte("moved"), stored))
+		assert.Nil(t, kv.Get([]byte("moved")))
+		assert.Equal(t, ErrDecrypt, kv.Iterator(func(k, v []byte) error {
+			return nil
+		}))
+
+		assert.NoError(t, kv.Delete([]byte("moved")))
+		assert.Equal(t, 1, kv.Count())
+	}
+
+	assert.Panics(t, func() { Wrap(Options{Cipher: 2, Keys: map[uint32][]byte{1: demoKey1}, KeyID: 1}, memory.Open()) })
+	assert.Panics(t, func() { Wrap(Options{Keys: map[uint32][]byte{1: demoKey1[:16]}, KeyID: 1}, memory.Open()) })
+	assert.Panics(t, func() { Wrap(Options{Keys: map[uint32][]byte{1: demoKey1}, KeyID: 2}, memory.Open()) })
+}
+
+func TestEncryptKeys(t *testing.T) {
+	ops := make(map[gkv.Op]int)
+	db := gkv.Wrap(memory.Open(), func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			ops[c.Op]++
+			return next(c)
+		}
+	})
+	kv := Wrap(Options{Keys: map[uint32][]byte{1: demoKey1}, KeyID: 1, EncryptKeys: true}, db)
+
+	for _, key := range []string{"user-1", "user-2", "order-1"} {
+		assert.NoError(t, kv.Put([]byte(key), []byte("value-"+key)))
+	}
+	assert.NoError(t, kv.Put([]byte("user-1"), []byte("value-user-1")))
+	// every put is a single write without a needless delete.
+	assert.Equal(t, map[gkv.Op]int{gkv.OpWrite: 4}, ops)
+	assert.Equal(t, 3, db.Count())
+	assert.Equal(t, []byte("value-user-2"), kv.Get([]byte("user-2")))
+
+	assert.NoError(t, db.Iterator(func(k, v []byte) error {
+		assert.False(t, bytes.Contains(k, []byte("user")))
+		return nil
+	}))
+
+	var keys []string
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("user-"), func(k, v []byte) error {
+		assert.Equal(t, "value-"+string(k), string(v))
+		keys = append(keys, string(k))
+		return nil
+	}))
+	assert.Len(t, keys, 2)
+
+	assert.NoError(t, kv.Delete([]byte("user-2")))
+	assert.Nil(t, kv.Get([]byte("user-2")))
+	assert.Equal(t, 2, db.Count())
+}
+
+func TestWrite(t *testing.T) {
+	db := memory.Open()
+	kv := Wrap(Options{Keys: map[uint32][]byte{1: demoKey1}, KeyID: 1}, db)
+
+	b := new(gkv.Batch)
+	b.Put([]byte("a1"), []byte("value1"))
+	b.Put([]byte("a2"), []byte("value2"))
+	b.Put([]byte("b1"), []byte("value3"))
+	b.Delete([]byte("a2"))
+	assert.NoError(t, kv.Write(b))
+	assert.Equal(t, 2, db.Count())
+
+	var values []string
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("a"), func(k, v []byte) error {
+		values = append(values, string(v))
+		return nil
+	}))
+	assert.Equal(t, []string{"value1"}, values)
+}
+
+func TestRekey(t *testing.T) {
+	// leveldb reuses the buffers of the keys and values it iterates over.
+	for _, open := range []func() gkv.KV{
+		func() gkv.KV { return memory.Open() },
+		func() gkv.KV {
+			os.RemoveAll("../data/test-encrypt-leveldb.db")
+			return leveldb.Open("../data/test-encrypt-leveldb.db")
+		},
+	} {
+		for _, encryptKeys := range []bool{false, true} {
+			db := open()
+			kv := Wrap(Options{Keys: map[uint32][]byte{1: demoKey1}, KeyID: 1, EncryptKeys: encryptKeys}, db)
+			for i := 0; i < 20; i++ {
+				key := fmt.Sprintf("key%02d", i)
+				assert.NoError(t, kv.Put([]byte(key), []byte("value-"+key)))
+			}
+
+			kv = Wrap(Options{
+				Keys:        map[uint32][]byte{1: demoKey1, 2: demoKey2},
+				KeyID:       2,
+				EncryptKeys: encryptKeys,
+			}, db)
+			assert.Equal(t, []byte("value-key01"), kv.Get([]byte("key01")))
+			assert.NoError(t, kv.Put([]byte("key02"), []byte("value-key02")))
+			assert.Equal(t, 20, db.Count())
+
+			assert.NoError(t, kv.Rekey())
+			assert.Equal(t, 20, db.Count())
+
+			kv = Wrap(Options{
+				Keys:        map[uint32][]byte{2: demoKey2},
+				KeyID:       2,
+				EncryptKeys: encryptKeys,
+			}, db)
+			var count int
+			assert.NoError(t, kv.Iterator(func(k, v []byte) error {
+				count++
+				assert.Equal(t, "value-"+string(k), string(v))
+				return nil
+			}))
+			assert.Equal(t, 20, count)
+			assert.NoError(t, db.Close())
+		}
+	}
+}
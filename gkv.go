@@ -1,6 +1,9 @@
 package gkv
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+)
 
 // DefaultTableName the default name of table.
 const DefaultTableName = "gkv"
@@ -8,6 +11,10 @@ const DefaultTableName = "gkv"
 // ErrTableName illegal table name error
 var ErrTableName = errors.New("illegal table name")
 
+// ErrStopIteration is returned by an iterator callback to stop the iteration,
+// it is never returned by Iterator itself.
+var ErrStopIteration = errors.New("stop iteration")
+
 // KV short for key-value,
 // interface contains all behaviors for key-value adapter.
 type KV interface {
@@ -26,7 +33,17 @@ type KV interface {
 	// Count returns the total number of all the keys.
 	Count() int
 	// Iterator creates an iterator for iterating over all the keys.
-	Iterator(func([]byte, []byte) bool) error
+	// The iteration stops at the first error of the backend or the callback,
+	// which is returned unless it is ErrStopIteration.
+	Iterator(func([]byte, []byte) error) error
+	// SetSync sets whether every write is flushed to stable storage.
+	SetSync(bool)
+	// Sync flushes all pending writes to stable storage.
+	Sync() error
+	// Compact reclaims the unused space of the storage.
+	Compact() error
+	// Stats returns the statistics of the storage.
+	Stats() Stats
 }
 
 // Instance is a function create a new KV Instance
@@ -69,6 +86,17 @@ func Put(key, value []byte) error {
 	return db.Put(key, value)
 }
 
+// PutSync sets the value for a key and flushes it to stable storage.
+func PutSync(key, value []byte) error {
+	if db == nil {
+		return errors.New("the db service is not started")
+	}
+	if err := db.Put(key, value); err != nil {
+		return err
+	}
+	return db.Sync()
+}
+
 // Get retrieves the value for a key.
 func Get(key []byte) []byte {
 	if db == nil {
@@ -85,6 +113,17 @@ func Delete(key []byte) error {
 	return db.Delete(key)
 }
 
+// DeleteSync deletes the given key and flushes it to stable storage.
+func DeleteSync(key []byte) error {
+	if db == nil {
+		return errors.New("the db service is not started")
+	}
+	if err := db.Delete(key); err != nil {
+		return err
+	}
+	return db.Sync()
+}
+
 // Count returns the total number of all the keys.
 func Count() int {
 	if db == nil {
@@ -94,9 +133,76 @@ func Count() int {
 }
 
 // Iterator creates an iterator for iterating over all the keys.
-func Iterator(f func([]byte, []byte) bool) error {
+// The iteration stops at the first error of the backend or the callback,
+// which is returned unless it is ErrStopIteration.
+func Iterator(f func([]byte, []byte) error) error {
 	if db == nil {
 		return nil
 	}
 	return db.Iterator(f)
 }
+
+// SetSync sets whether every write is flushed to stable storage.
+func SetSync(sync bool) {
+	if db != nil {
+		db.SetSync(sync)
+	}
+}
+
+// Sync flushes all pending writes to stable storage.
+func Sync() error {
+	if db == nil {
+		return nil
+	}
+	return db.Sync()
+}
+
+// Compact reclaims the unused space of the storage.
+func Compact() error {
+	if db == nil {
+		return nil
+	}
+	return db.Compact()
+}
+
+// Write writes all the writes of the batch, atomically if the adapter is a Batcher.
+func Write(b *Batch) error {
+	if db == nil {
+		return errors.New("the db service is not started")
+	}
+	return b.Apply(db)
+}
+
+// WriteSync writes all the writes of the batch, and flushes them to stable storage.
+func WriteSync(b *Batch) error {
+	if db == nil {
+		return errors.New("the db service is not started")
+	}
+	if err := b.Apply(db); err != nil {
+		return err
+	}
+	return db.Sync()
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix,
+// it iterates over all the keys unless the adapter is a Prefixer.
+func Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	if db == nil {
+		return nil
+	}
+	return PrefixOf(db, prefix, f)
+}
+
+// PrefixOf creates an iterator for iterating over the keys of kv with the prefix,
+// it iterates over all the keys unless kv is a Prefixer.
+func PrefixOf(kv KV, prefix []byte, f func([]byte, []byte) error) error {
+	if p, ok := kv.(Prefixer); ok {
+		return p.Prefix(prefix, f)
+	}
+	return kv.Iterator(func(k, v []byte) error {
+		if bytes.HasPrefix(k, prefix) {
+			return f(k, v)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,300 @@
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/WindomZ/gkv"
+)
+
+// record is a key-value pair in the file, the key and value are stored as
+// strings if they are valid UTF-8, otherwise as base64 in key64 and value64.
+type record struct {
+	Key     *string `json:"key,omitempty"`
+	Key64   []byte  `json:"key64,omitempty"`
+	Value   *string `json:"value,omitempty"`
+	Value64 []byte  `json:"value64,omitempty"`
+}
+
+// encode returns b as a string if it is valid UTF-8, otherwise as bytes.
+func encode(b []byte) (*string, []byte) {
+	if utf8.Valid(b) {
+		s := string(b)
+		return &s, nil
+	}
+	return nil, b
+}
+
+// decode returns the bytes of the string s or b.
+func decode(s *string, b []byte) []byte {
+	if s != nil {
+		return []byte(*s)
+	}
+	return append([]byte{}, b...)
+}
+
+// KV is an adapter keeping all the tables in a single human-readable JSON file,
+// which is loaded into memory on open and rewritten atomically on every write.
+type KV struct {
+	mu     sync.RWMutex
+	path   string
+	sync   bool
+	tables map[string]map[string][]byte
+	table  string
+}
+
+// Open creates a new jsonfile driver by storage file path.
+// paths are storage file paths.
+func Open(paths ...string) gkv.KV {
+	var path string
+	if len(paths) != 0 {
+		path = paths[0]
+	} else {
+		path = filepath.Join(gkv.ProjectDir(), "data", "data.json")
+	}
+	kv := &KV{
+		path:   path,
+		sync:   true,
+		tables: make(map[string]map[string][]byte),
+		table:  gkv.DefaultTableName,
+	}
+	if err := kv.load(); err != nil {
+		panic(err)
+	}
+	return kv
+}
+
+// load reads all the tables from the file, a missing file is empty.
+func (kv *KV) load() error {
+	data, err := os.ReadFile(kv.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var tables map[string][]record
+	if err = json.Unmarshal(data, &tables); err != nil {
+		return err
+	}
+	for name, records := range tables {
+		table := make(map[string][]byte, len(records))
+		for _, r := range records {
+			table[string(decode(r.Key, r.Key64))] = decode(r.Value, r.Value64)
+		}
+		kv.tables[name] = table
+	}
+	return nil
+}
+
+// save writes all the tables into a temporary file,
+// which replaces the file by renaming.
+func (kv *KV) save() error {
+	tables := make(map[string][]record, len(kv.tables))
+	for name, table := range kv.tables {
+		keys := make([]string, 0, len(table))
+		for key := range table {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		records := make([]record, 0, len(keys))
+		for _, key := range keys {
+			var r record
+			r.Key, r.Key64 = encode([]byte(key))
+			r.Value, r.Value64 = encode(table[key])
+			records = append(records, r)
+		}
+		tables[name] = records
+	}
+	data, err := json.MarshalIndent(tables, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := kv.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err == nil && kv.sync {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, kv.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if kv.sync {
+		// the rename is durable only once the directory is flushed.
+		return gkv.SyncFile(filepath.Dir(kv.path))
+	}
+	return nil
+}
+
+// DB returns the native DB of the adapter,
+// which is the path of the file.
+func (kv *KV) DB() interface{} {
+	return kv.path
+}
+
+// Close releases all database resources.
+func (kv *KV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.save()
+}
+
+// Register initializes a new database if it doesn't already exist,
+// the table name must be valid UTF-8.
+func (kv *KV) Register(table []byte) error {
+	if len(table) == 0 || !utf8.Valid(table) {
+		return gkv.ErrTableName
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if _, ok := kv.tables[string(table)]; ok {
+		kv.table = string(table)
+		return nil
+	}
+	kv.tables[string(table)] = make(map[string][]byte)
+	if err := kv.save(); err != nil {
+		delete(kv.tables, string(table))
+		return err
+	}
+	kv.table = string(table)
+	return nil
+}
+
+// Put sets the value for a key.
+func (kv *KV) Put(key, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	table, ok := kv.tables[kv.table]
+	if !ok {
+		table = make(map[string][]byte)
+		kv.tables[kv.table] = table
+	}
+	old, exists := table[string(key)]
+	table[string(key)] = append([]byte{}, value...)
+	if err := kv.save(); err != nil {
+		// the table in memory is rolled back to the file.
+		if !ok {
+			delete(kv.tables, kv.table)
+		} else if exists {
+			table[string(key)] = old
+		} else {
+			delete(table, string(key))
+		}
+		return err
+	}
+	return nil
+}
+
+// Get retrieves the value for a key.
+func (kv *KV) Get(key []byte) []byte {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	value, ok := kv.tables[kv.table][string(key)]
+	if !ok {
+		return nil
+	}
+	return append([]byte{}, value...)
+}
+
+// Delete deletes the given key from the database resources.
+func (kv *KV) Delete(key []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	table := kv.tables[kv.table]
+	old, ok := table[string(key)]
+	if !ok {
+		return nil
+	}
+	delete(table, string(key))
+	if err := kv.save(); err != nil {
+		// the table in memory is rolled back to the file.
+		table[string(key)] = old
+		return err
+	}
+	return nil
+}
+
+// Count returns the total number of all the keys.
+func (kv *KV) Count() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return len(kv.tables[kv.table])
+}
+
+// Iterator creates an iterator for iterating over all the keys in order.
+// It iterates over a snapshot, so the callback may modify the database,
+// and the keys and values passed to it are copies.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	kv.mu.RLock()
+	table := kv.tables[kv.table]
+	keys := make([]string, 0, len(table))
+	values := make(map[string][]byte, len(table))
+	for key, value := range table {
+		keys = append(keys, key)
+		values[key] = value
+	}
+	kv.mu.RUnlock()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := f([]byte(key), append([]byte{}, values[key]...)); err == gkv.ErrStopIteration {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSync sets whether every write is flushed to stable storage,
+// it is true by default.
+func (kv *KV) SetSync(sync bool) {
+	kv.mu.Lock()
+	kv.sync = sync
+	kv.mu.Unlock()
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	if err := gkv.SyncFile(kv.path); err != nil {
+		return err
+	}
+	return gkv.SyncFile(filepath.Dir(kv.path))
+}
+
+// Compact reclaims the unused space of the storage,
+// the file is rewritten on every write, so there is nothing to do.
+func (kv *KV) Compact() error {
+	return nil
+}
+
+// Stats returns the statistics of the storage.
+func (kv *KV) Stats() gkv.Stats {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return gkv.Stats{
+		Keys: len(kv.tables[kv.table]),
+		Size: gkv.DiskSize(kv.path),
+		Backend: map[string]interface{}{
+			"tables": len(kv.tables),
+		},
+	}
+}
+
+func init() {
+	gkv.Register(Open)
+}
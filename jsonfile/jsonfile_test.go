@@ -0,0 +1,140 @@
+package jsonfile
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demo *KV
+var (
+	demoTable = []byte("table-表_1 2%3")
+	demoKey   = []byte("key-键_4 5%6")
+	demoValue = []byte("value-值_7 8%9")
+)
+
+func TestOpen(t *testing.T) {
+	db := Open("../data/test-jsonfile.json")
+	if v, ok := db.(*KV); ok {
+		demo = v
+	}
+}
+
+func TestDB(t *testing.T) {
+	assert.NotEmpty(t, demo.DB())
+}
+
+func TestRegister(t *testing.T) {
+	assert.NoError(t, demo.Register(demoTable))
+}
+
+func TestPut(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestIterator(t *testing.T) {
+	cnt := 0
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		cnt++
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		v[0] = 'V'
+		return nil
+	}))
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestReopen(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0xfe}
+	assert.NoError(t, demo.Put(binary, binary))
+	assert.NoError(t, demo.Close())
+
+	data, err := os.ReadFile("../data/test-jsonfile.json")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"value": "`+string(demoValue)+`"`)
+	assert.Contains(t, string(data), `"value64": "AP/+"`)
+
+	demo = Open("../data/test-jsonfile.json").(*KV)
+	assert.Equal(t, gkv.ErrTableName, demo.Register([]byte{0xff}))
+	assert.NoError(t, demo.Register(demoTable))
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, binary, demo.Get(binary))
+	assert.NoError(t, demo.Delete(binary))
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestSaveError(t *testing.T) {
+	// the temporary file cannot be created over a directory.
+	tmp := "../data/test-jsonfile.json.tmp"
+	assert.NoError(t, os.MkdirAll(tmp, 0755))
+	assert.Error(t, demo.Put(demoKey, []byte("value")))
+	assert.Error(t, demo.Put([]byte("key"), []byte("value")))
+	assert.Error(t, demo.Delete(demoKey))
+	assert.Error(t, demo.Register([]byte("table")))
+	assert.NoError(t, os.Remove(tmp))
+
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Nil(t, demo.Get([]byte("key")))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Close())
+	demo = Open("../data/test-jsonfile.json").(*KV)
+	assert.NoError(t, demo.Register(demoTable))
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestDelete(t *testing.T) {
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.Equal(t, 0, demo.Count())
+}
+
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Equal(t, 1, s.Backend["tables"])
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestClose(t *testing.T) {
+	assert.NoError(t, demo.Close())
+}
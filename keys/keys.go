@@ -0,0 +1,291 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrKey illegal encoded key error
+	ErrKey = errors.New("illegal encoded key")
+	// ErrType unsupported element type error
+	ErrType = errors.New("unsupported element type")
+)
+
+// Tuple is a list of elements encoded into a key, which may be nil, []byte,
+// string, signed and unsigned integers, float64, bool, time.Time or Tuple.
+type Tuple []interface{}
+
+// The type codes of the elements, which follow the FoundationDB tuple layer,
+// except that time.Time uses 0x40 from the range left for user types.
+const (
+	codeNil     = 0x00
+	codeBytes   = 0x01
+	codeString  = 0x02
+	codeNested  = 0x05
+	codeNegMin  = 0x0c
+	codeInt     = 0x14
+	codePosMax  = 0x1c
+	codeFloat64 = 0x21
+	codeFalse   = 0x26
+	codeTrue    = 0x27
+	codeTime    = 0x40
+)
+
+// Pack encodes the elements into a key, whose bytes sort in the same order
+// as the elements. The key of a tuple is a prefix of the keys of all the
+// tuples starting with it, so gkv.Prefix scans them on any adapter.
+func Pack(elems ...interface{}) ([]byte, error) {
+	return Tuple(elems).Pack()
+}
+
+// MustPack is like Pack but panics if an element can't be encoded.
+func MustPack(elems ...interface{}) []byte {
+	key, err := Pack(elems...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Pack encodes the tuple into a key.
+func (t Tuple) Pack() ([]byte, error) {
+	return t.encode(nil, false)
+}
+
+// encode appends the elements to buf, nil is escaped in a nested tuple.
+func (t Tuple) encode(buf []byte, nested bool) ([]byte, error) {
+	for _, elem := range t {
+		switch v := elem.(type) {
+		case nil:
+			buf = append(buf, codeNil)
+			if nested {
+				buf = append(buf, 0xff)
+			}
+		case []byte:
+			buf = appendBytes(buf, codeBytes, v)
+		case string:
+			buf = appendBytes(buf, codeString, []byte(v))
+		case int:
+			buf = appendInt(buf, int64(v))
+		case int8:
+			buf = appendInt(buf, int64(v))
+		case int16:
+			buf = appendInt(buf, int64(v))
+		case int32:
+			buf = appendInt(buf, int64(v))
+		case int64:
+			buf = appendInt(buf, v)
+		case uint:
+			buf = appendUint(buf, uint64(v))
+		case uint8:
+			buf = appendUint(buf, uint64(v))
+		case uint16:
+			buf = appendUint(buf, uint64(v))
+		case uint32:
+			buf = appendUint(buf, uint64(v))
+		case uint64:
+			buf = appendUint(buf, v)
+		case float64:
+			bits := math.Float64bits(v)
+			if bits>>63 != 0 {
+				bits = ^bits
+			} else {
+				bits ^= 1 << 63
+			}
+			buf = binary.BigEndian.AppendUint64(append(buf, codeFloat64), bits)
+		case bool:
+			if v {
+				buf = append(buf, codeTrue)
+			} else {
+				buf = append(buf, codeFalse)
+			}
+		case time.Time:
+			buf = binary.BigEndian.AppendUint64(append(buf, codeTime), uint64(v.Unix())^(1<<63))
+			buf = binary.BigEndian.AppendUint32(buf, uint32(v.Nanosecond()))
+		case Tuple:
+			var err error
+			if buf, err = v.encode(append(buf, codeNested), true); err != nil {
+				return nil, err
+			}
+			buf = append(buf, 0x00)
+		default:
+			return nil, ErrType
+		}
+	}
+	return buf, nil
+}
+
+// appendBytes appends b terminated by 0x00, in which 0x00 is escaped as 0x00 0xff.
+func appendBytes(buf []byte, code byte, b []byte) []byte {
+	buf = append(buf, code)
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == 0x00 {
+			buf = append(buf, 0xff)
+		}
+	}
+	return append(buf, 0x00)
+}
+
+// appendUint appends v in as few big-endian bytes as possible,
+// whose number is added to the type code.
+func appendUint(buf []byte, v uint64) []byte {
+	n := size(v)
+	buf = append(buf, byte(codeInt+n))
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+// appendInt appends v like appendUint, a negative v is appended as
+// the ones' complement of its absolute value, whose number of bytes
+// is subtracted from the type code.
+func appendInt(buf []byte, v int64) []byte {
+	if v >= 0 {
+		return appendUint(buf, uint64(v))
+	}
+	u := uint64(-v)
+	n := size(u)
+	buf = append(buf, byte(codeInt-n))
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, ^byte(u>>(8*i)))
+	}
+	return buf
+}
+
+// size returns the number of bytes of v without leading zeros.
+func size(v uint64) int {
+	n := 0
+	for ; v != 0; v >>= 8 {
+		n++
+	}
+	return n
+}
+
+// Unpack decodes a key encoded by Pack into its elements. Integers decode
+// as int64, or uint64 if they overflow it, and times decode in UTC.
+func Unpack(key []byte) (Tuple, error) {
+	t, rest, err := decode(key, false)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, ErrKey
+	}
+	return t, nil
+}
+
+// decode decodes the elements of b, a nested tuple ends at an unescaped 0x00,
+// and returns the rest after it.
+func decode(b []byte, nested bool) (Tuple, []byte, error) {
+	t := Tuple{}
+	for len(b) != 0 {
+		code := b[0]
+		b = b[1:]
+		switch {
+		case code == codeNil:
+			if !nested {
+				t = append(t, nil)
+			} else if len(b) != 0 && b[0] == 0xff {
+				t = append(t, nil)
+				b = b[1:]
+			} else {
+				return t, b, nil
+			}
+		case code == codeBytes, code == codeString:
+			var v []byte
+			var ok bool
+			if v, b, ok = readBytes(b); !ok {
+				return nil, nil, ErrKey
+			}
+			if code == codeString {
+				t = append(t, string(v))
+			} else {
+				t = append(t, v)
+			}
+		case code == codeNested:
+			v, rest, err := decode(b, true)
+			if err != nil {
+				return nil, nil, err
+			}
+			t, b = append(t, v), rest
+		case code >= codeNegMin && code <= codePosMax:
+			n := int(code) - codeInt
+			neg := n < 0
+			if neg {
+				n = -n
+			}
+			if len(b) < n {
+				return nil, nil, ErrKey
+			}
+			var u uint64
+			for _, c := range b[:n] {
+				if neg {
+					c = ^c
+				}
+				u = u<<8 | uint64(c)
+			}
+			b = b[n:]
+			if neg {
+				if u > 1<<63 {
+					return nil, nil, ErrKey
+				}
+				t = append(t, -int64(u))
+			} else if u > math.MaxInt64 {
+				t = append(t, u)
+			} else {
+				t = append(t, int64(u))
+			}
+		case code == codeFloat64:
+			if len(b) < 8 {
+				return nil, nil, ErrKey
+			}
+			bits := binary.BigEndian.Uint64(b)
+			if bits>>63 != 0 {
+				bits ^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			t, b = append(t, math.Float64frombits(bits)), b[8:]
+		case code == codeFalse, code == codeTrue:
+			t = append(t, code == codeTrue)
+		case code == codeTime:
+			if len(b) < 12 {
+				return nil, nil, ErrKey
+			}
+			sec := int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+			nsec := int64(binary.BigEndian.Uint32(b[8:]))
+			t, b = append(t, time.Unix(sec, nsec).UTC()), b[12:]
+		default:
+			return nil, nil, ErrKey
+		}
+	}
+	if nested {
+		// a nested tuple must be terminated.
+		return nil, nil, ErrKey
+	}
+	return t, b, nil
+}
+
+// readBytes reads the bytes terminated by an unescaped 0x00,
+// and returns the rest after it.
+func readBytes(b []byte) ([]byte, []byte, bool) {
+	v := []byte{}
+	for {
+		i := bytes.IndexByte(b, 0x00)
+		if i < 0 {
+			return nil, nil, false
+		}
+		v = append(v, b[:i]...)
+		if i+1 < len(b) && b[i+1] == 0xff {
+			v = append(v, 0x00)
+			b = b[i+2:]
+			continue
+		}
+		return v, b[i+1:], true
+	}
+}
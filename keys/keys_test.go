@@ -0,0 +1,120 @@
+package keys
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/WindomZ/gkv"
+	_ "github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demoTime = time.Date(2017, 5, 1, 12, 30, 0, 500, time.UTC)
+
+func TestPack(t *testing.T) {
+	tuple := Tuple{
+		nil, []byte{0x00, 0x01, 0xff}, "key-键_1 2%3", "",
+		int64(0), int64(1), int64(-1), int64(math.MaxInt64), int64(math.MinInt64),
+		uint64(math.MaxUint64), 1.5, -0.25, true, false, demoTime,
+		Tuple{nil, "nested", Tuple{}, int64(-300)},
+	}
+	key, err := tuple.Pack()
+	assert.NoError(t, err)
+
+	v, err := Unpack(key)
+	assert.NoError(t, err)
+	assert.Equal(t, tuple, v)
+
+	v, err = Unpack(MustPack(1, int8(-2), uint16(3)))
+	assert.NoError(t, err)
+	assert.Equal(t, Tuple{int64(1), int64(-2), int64(3)}, v)
+
+	_, err = Pack(struct{}{})
+	assert.Equal(t, ErrType, err)
+	assert.Panics(t, func() { MustPack(1.5e0, float32(1)) })
+}
+
+func TestUnpack(t *testing.T) {
+	for _, key := range [][]byte{
+		{codeBytes, 'a'},
+		{codeInt + 2, 0x01},
+		{codeFloat64, 0x00},
+		{codeTime, 0x00},
+		{codeNested, codeInt},
+		{0xff},
+	} {
+		_, err := Unpack(key)
+		assert.Equal(t, ErrKey, err, key)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tuples := []Tuple{
+		{nil},
+		{[]byte{}},
+		{[]byte{0x00}},
+		{[]byte{0x00, 0x00}},
+		{[]byte{0x01}},
+		{"a"},
+		{"a", int64(1)},
+		{"a\x00b"},
+		{"ab"},
+		{"b"},
+		{Tuple{"a"}},
+		{Tuple{"a", nil}},
+		{Tuple{"b"}},
+		{int64(math.MinInt64)},
+		{int64(-70000)},
+		{int64(-256)},
+		{int64(-255)},
+		{int64(-1)},
+		{int64(0)},
+		{int64(1)},
+		{int64(255)},
+		{int64(256)},
+		{int64(math.MaxInt64)},
+		{uint64(math.MaxUint64)},
+		{math.Inf(-1)},
+		{-1.5},
+		{0.0},
+		{1.5},
+		{math.Inf(1)},
+		{false},
+		{true},
+		{time.Unix(-1, 0).UTC()},
+		{time.Unix(0, 0).UTC()},
+		{time.Unix(0, 1).UTC()},
+		{demoTime},
+	}
+	packed := make([][]byte, 0, len(tuples))
+	for _, tuple := range tuples {
+		packed = append(packed, MustPack(tuple...))
+	}
+	for i := 1; i < len(packed); i++ {
+		assert.True(t, bytes.Compare(packed[i-1], packed[i]) < 0, tuples[i])
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	assert.NoError(t, gkv.Open([]byte("keys")))
+	defer gkv.Close()
+
+	for _, id := range []int64{10, -1, 2, 300} {
+		assert.NoError(t, gkv.Put(MustPack("tenant", demoTime, id), []byte("value")))
+		assert.NoError(t, gkv.Put(MustPack("tenant\x00other", demoTime, id), []byte("other")))
+	}
+
+	var ids []int64
+	assert.NoError(t, gkv.Prefix(MustPack("tenant", demoTime), func(k, v []byte) error {
+		tuple, err := Unpack(k)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, []byte("value"), v)
+		ids = append(ids, tuple[2].(int64))
+		return nil
+	}))
+	assert.Equal(t, []int64{-1, 2, 10, 300}, ids)
+}
@@ -2,14 +2,19 @@ package leveldb
 
 import (
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/WindomZ/gkv"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // KV is a goleveldb/leveldb adapter.
 type KV struct {
-	db *leveldb.DB
+	db   *leveldb.DB
+	path string
+	wo   atomic.Pointer[opt.WriteOptions]
 }
 
 // Open creates a new leveldb driver by storage file path.
@@ -25,13 +30,16 @@ func Open(paths ...string) gkv.KV {
 	if err != nil {
 		panic(err)
 	}
-	return &KV{
-		db: db,
+	kv := &KV{
+		db:   db,
+		path: path,
 	}
+	kv.wo.Store(&opt.WriteOptions{})
+	return kv
 }
 
 // DB returns the native DB of the adapter.
-func (kv KV) DB() interface{} {
+func (kv *KV) DB() interface{} {
 	return kv.db
 }
 
@@ -47,7 +55,7 @@ func (kv *KV) Register(table []byte) error {
 
 // Put sets the value for a key.
 func (kv *KV) Put(key, value []byte) error {
-	return kv.db.Put(key, value, nil)
+	return kv.db.Put(key, value, kv.wo.Load())
 }
 
 // Get retrieves the value for a key.
@@ -58,7 +66,7 @@ func (kv *KV) Get(key []byte) (value []byte) {
 
 // Delete deletes the given key from the database resources.
 func (kv *KV) Delete(key []byte) error {
-	return kv.db.Delete(key, nil)
+	return kv.db.Delete(key, kv.wo.Load())
 }
 
 // Count returns the total number of all the keys.
@@ -72,15 +80,69 @@ func (kv *KV) Count() (i int) {
 }
 
 // Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
+func (kv *KV) Iterator(f func([]byte, []byte) error) (err error) {
 	iter := kv.db.NewIterator(nil, nil)
 	for iter.Next() {
-		if !f(iter.Key(), iter.Value()) {
+		if err = f(iter.Key(), iter.Value()); err != nil {
 			break
 		}
 	}
 	iter.Release()
-	return iter.Error()
+	if err == nil {
+		err = iter.Error()
+	} else if err == gkv.ErrStopIteration {
+		err = nil
+	}
+	return
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	kv.wo.Store(&opt.WriteOptions{Sync: sync})
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	// leveldb has no explicit sync, so flush the journal files,
+	// every write has already reached them.
+	files, err := filepath.Glob(filepath.Join(kv.path, "*.log"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = gkv.SyncFile(file); err != nil {
+			return err
+		}
+	}
+	return gkv.SyncFile(kv.path)
+}
+
+// Compact reclaims the unused space of the storage.
+func (kv *KV) Compact() error {
+	return kv.db.CompactRange(util.Range{})
+}
+
+// Stats returns the statistics of the storage,
+// the leveldb properties are the backend-specific ones.
+func (kv *KV) Stats() gkv.Stats {
+	s := gkv.Stats{
+		Keys:    kv.Count(),
+		Size:    gkv.DiskSize(kv.path),
+		Backend: make(map[string]interface{}),
+	}
+	for _, name := range []string{
+		"leveldb.stats",
+		"leveldb.blockpool",
+		"leveldb.cachedblock",
+		"leveldb.openedtables",
+		"leveldb.alivesnaps",
+		"leveldb.aliveiters",
+	} {
+		if v, err := kv.db.GetProperty(name); err == nil {
+			s.Backend[name] = v
+		}
+	}
+	return s
 }
 
 func init() {
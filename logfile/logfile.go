@@ -0,0 +1,184 @@
+package logfile
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/WindomZ/gkv"
+)
+
+// KV is a dependency-free, bitcask-style adapter.
+// Every table is an append-only data file with an in-memory hash index,
+// which is saved into a hint file on close and compaction to open fast.
+type KV struct {
+	mu     sync.RWMutex
+	path   string
+	sync   bool
+	tables map[string]*table
+	table  *table
+}
+
+// Open creates a new logfile driver by storage file path.
+// paths are storage file paths.
+func Open(paths ...string) gkv.KV {
+	var path string
+	if len(paths) != 0 {
+		path = paths[0]
+	} else {
+		path = filepath.Join(gkv.ProjectDir(), "data", "logfile")
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		panic(err)
+	}
+	kv := &KV{
+		path:   path,
+		tables: make(map[string]*table),
+	}
+	if err := kv.Register([]byte(gkv.DefaultTableName)); err != nil {
+		panic(err)
+	}
+	return kv
+}
+
+// DB returns the native DB of the adapter,
+// which is the directory of the data files.
+func (kv *KV) DB() interface{} {
+	return kv.path
+}
+
+// Close releases all database resources.
+func (kv *KV) Close() (err error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for name, t := range kv.tables {
+		if e := t.close(); err == nil {
+			err = e
+		}
+		delete(kv.tables, name)
+	}
+	return
+}
+
+// Register initializes a new database if it doesn't already exist,
+// the files of a table are named by the hex of the table name.
+func (kv *KV) Register(table []byte) error {
+	if len(table) == 0 {
+		return gkv.ErrTableName
+	}
+	name := hex.EncodeToString(table)
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	t, ok := kv.tables[name]
+	if !ok {
+		var err error
+		if t, err = openTable(filepath.Join(kv.path, name)); err != nil {
+			return err
+		}
+		kv.tables[name] = t
+	}
+	kv.table = t
+	return nil
+}
+
+// Put sets the value for a key.
+func (kv *KV) Put(key, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.table.write(key, value, 0, kv.sync)
+}
+
+// Get retrieves the value for a key.
+func (kv *KV) Get(key []byte) []byte {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	value, _ := kv.table.get(key)
+	return value
+}
+
+// Delete deletes the given key from the database resources.
+func (kv *KV) Delete(key []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if _, ok := kv.table.index[string(key)]; !ok {
+		return nil
+	}
+	return kv.table.write(key, nil, flagDelete, kv.sync)
+}
+
+// Count returns the total number of all the keys.
+func (kv *KV) Count() int {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return len(kv.table.index)
+}
+
+// Iterator creates an iterator for iterating over all the keys in order.
+// It iterates over the keys at the start, so the callback may modify
+// the database, keys deleted meanwhile are skipped.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	kv.mu.RLock()
+	keys := kv.table.keys()
+	kv.mu.RUnlock()
+	for _, key := range keys {
+		kv.mu.RLock()
+		value, err := kv.table.get([]byte(key))
+		kv.mu.RUnlock()
+		if err == nil && value != nil {
+			err = f([]byte(key), value)
+		}
+		if err == gkv.ErrStopIteration {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	kv.mu.Lock()
+	kv.sync = sync
+	kv.mu.Unlock()
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	for _, t := range kv.tables {
+		if err := t.file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact reclaims the unused space of the storage,
+// it rewrites the live records of the table into a fresh data file.
+func (kv *KV) Compact() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.table.compact()
+}
+
+// Stats returns the statistics of the storage of the table,
+// the free space is the records overwritten or deleted.
+func (kv *KV) Stats() gkv.Stats {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	return gkv.Stats{
+		Keys: len(kv.table.index),
+		Size: kv.table.size,
+		Free: kv.table.dead,
+		Backend: map[string]interface{}{
+			"tables": len(kv.tables),
+		},
+	}
+}
+
+func init() {
+	gkv.Register(Open)
+}
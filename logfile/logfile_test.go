@@ -0,0 +1,123 @@
+package logfile
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demo *KV
+var (
+	demoTable = []byte("table-表_1 2%3")
+	demoKey   = []byte("key-键_4 5%6")
+	demoValue = []byte("value-值_7 8%9")
+)
+
+func TestOpen(t *testing.T) {
+	db := Open("../data/test-logfile")
+	if v, ok := db.(*KV); ok {
+		demo = v
+	}
+}
+
+func TestDB(t *testing.T) {
+	assert.NotEmpty(t, demo.DB())
+}
+
+func TestRegister(t *testing.T) {
+	assert.NoError(t, demo.Register(demoTable))
+}
+
+func TestPut(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestIterator(t *testing.T) {
+	cnt := 0
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		cnt++
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
+	}))
+	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestReopen(t *testing.T) {
+	assert.NoError(t, demo.Put([]byte("deleted"), demoValue))
+	assert.NoError(t, demo.Delete([]byte("deleted")))
+	assert.NoError(t, demo.Close())
+
+	// a torn record left by a crash is dropped on open.
+	path := filepath.Join("../data/test-logfile", hex.EncodeToString(demoTable))
+	f, err := os.OpenFile(path+".data", os.O_WRONLY|os.O_APPEND, 0644)
+	if assert.NoError(t, err) {
+		f.Write(record([]byte("torn"), demoValue, 0)[:headerSize+2])
+		f.Close()
+	}
+
+	demo = Open("../data/test-logfile").(*KV)
+	assert.NoError(t, demo.Register(demoTable))
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Nil(t, demo.Get([]byte("deleted")))
+	assert.Nil(t, demo.Get([]byte("torn")))
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestDelete(t *testing.T) {
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.Equal(t, 0, demo.Count())
+}
+
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.True(t, demo.Stats().Free > s.Free)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestClose(t *testing.T) {
+	assert.NoError(t, demo.Close())
+}
@@ -0,0 +1,322 @@
+package logfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A record in the data file is laid out as:
+//
+//	crc32 | key size | value size | flags | key | value
+//
+// crc32 covers everything after itself, the sizes are uint32
+// and flags marks the record as a delete.
+const (
+	headerSize = 13
+	flagDelete = 1
+)
+
+// hintMagic is the head of a hint file, which is followed by the size of
+// the data file it covers, the size of dead records, the entries:
+//
+//	key size | value size | value offset | key
+//
+// and crc32 of everything before.
+var hintMagic = []byte("GKVHINT1")
+
+// entry locates the value of a key in the data file.
+type entry struct {
+	offset int64
+	size   uint32
+}
+
+// table is an append-only data file with an in-memory hash index,
+// the newest record of a key wins.
+type table struct {
+	path  string
+	file  *os.File
+	size  int64
+	dead  int64
+	index map[string]entry
+}
+
+// openTable opens the table of the data file path.data,
+// it loads the index from the hint file path.hint and the records after it.
+func openTable(path string) (*table, error) {
+	f, err := os.OpenFile(path+".data", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t := &table{
+		path:  path,
+		file:  f,
+		index: make(map[string]entry),
+	}
+	if err = t.load(t.loadHint()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// loadHint loads the index from the hint file,
+// and returns the size of the data file it covers, 0 if it is not usable.
+func (t *table) loadHint() int64 {
+	data, err := os.ReadFile(t.path + ".hint")
+	if err != nil || len(data) < len(hintMagic)+20 || !bytes.HasPrefix(data, hintMagic) {
+		return 0
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return 0
+	}
+	body = body[len(hintMagic):]
+	size := int64(binary.BigEndian.Uint64(body))
+	dead := int64(binary.BigEndian.Uint64(body[8:]))
+	if info, err := t.file.Stat(); err != nil || info.Size() < size {
+		return 0
+	}
+	index := make(map[string]entry)
+	for body = body[16:]; len(body) > 0; {
+		if len(body) < 16 {
+			return 0
+		}
+		n := binary.BigEndian.Uint32(body)
+		e := entry{
+			size:   binary.BigEndian.Uint32(body[4:]),
+			offset: int64(binary.BigEndian.Uint64(body[8:])),
+		}
+		if uint32(len(body)-16) < n {
+			return 0
+		}
+		index[string(body[16:16+n])] = e
+		body = body[16+n:]
+	}
+	t.index, t.size, t.dead = index, size, dead
+	return size
+}
+
+// load loads the records of the data file from offset into the index,
+// a torn or corrupt tail left by a crash is truncated.
+func (t *table) load(offset int64) error {
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.NewSectionReader(t.file, offset, info.Size()-offset))
+	header := make([]byte, headerSize)
+	for {
+		if _, err = io.ReadFull(r, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return t.truncate(offset)
+		}
+		k := binary.BigEndian.Uint32(header[4:])
+		v := binary.BigEndian.Uint32(header[8:])
+		body := make([]byte, int(k)+int(v))
+		if _, err = io.ReadFull(r, body); err != nil {
+			return t.truncate(offset)
+		}
+		sum := crc32.ChecksumIEEE(header[4:])
+		if crc32.Update(sum, crc32.IEEETable, body) != binary.BigEndian.Uint32(header) {
+			return t.truncate(offset)
+		}
+		size := int64(headerSize) + int64(k) + int64(v)
+		t.apply(string(body[:k]), header[12]&flagDelete != 0, entry{
+			offset: offset + headerSize + int64(k),
+			size:   v,
+		}, size)
+		offset += size
+	}
+	t.size = offset
+	return nil
+}
+
+// truncate drops the data file from offset on.
+func (t *table) truncate(offset int64) error {
+	t.size = offset
+	return t.file.Truncate(offset)
+}
+
+// apply applies a record of size bytes to the index.
+func (t *table) apply(key string, deleted bool, e entry, size int64) {
+	if old, ok := t.index[key]; ok {
+		t.dead += headerSize + int64(len(key)) + int64(old.size)
+	}
+	if deleted {
+		delete(t.index, key)
+		t.dead += size
+	} else {
+		t.index[key] = e
+	}
+}
+
+// record returns the encoded record of the key and value.
+func record(key, value []byte, flags byte) []byte {
+	buf := make([]byte, headerSize, headerSize+len(key)+len(value))
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(key)))
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(value)))
+	buf[12] = flags
+	buf = append(append(buf, key...), value...)
+	binary.BigEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// write appends a record of the key and value to the data file.
+func (t *table) write(key, value []byte, flags byte, sync bool) error {
+	buf := record(key, value, flags)
+	if _, err := t.file.WriteAt(buf, t.size); err != nil {
+		return err
+	}
+	if sync {
+		if err := t.file.Sync(); err != nil {
+			return err
+		}
+	}
+	t.apply(string(key), flags&flagDelete != 0, entry{
+		offset: t.size + headerSize + int64(len(key)),
+		size:   uint32(len(value)),
+	}, int64(len(buf)))
+	t.size += int64(len(buf))
+	return nil
+}
+
+// get returns the value of the key, or nil if it doesn't exist.
+func (t *table) get(key []byte) ([]byte, error) {
+	e, ok := t.index[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	value := make([]byte, e.size)
+	if _, err := t.file.ReadAt(value, e.offset); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// keys returns the sorted keys of the table.
+func (t *table) keys() []string {
+	keys := make([]string, 0, len(t.index))
+	for key := range t.index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeHint writes the index into the hint file atomically.
+func (t *table) writeHint() error {
+	buf := append([]byte{}, hintMagic...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(t.size))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(t.dead))
+	for key, e := range t.index {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(key)))
+		buf = binary.BigEndian.AppendUint32(buf, e.size)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(e.offset))
+		buf = append(buf, key...)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return writeFile(t.path+".hint", buf)
+}
+
+// writeFile writes data into the file atomically by renaming a temporary file.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// compact rewrites the live records into a fresh data file,
+// which replaces the old one, and writes the hint file of it.
+func (t *table) compact() error {
+	tmp := t.path + ".data.compact"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	index := make(map[string]entry, len(t.index))
+	w := bufio.NewWriter(f)
+	var size int64
+	for _, key := range t.keys() {
+		var value []byte
+		if value, err = t.get([]byte(key)); err != nil {
+			break
+		}
+		buf := record([]byte(key), value, 0)
+		if _, err = w.Write(buf); err != nil {
+			break
+		}
+		index[key] = entry{
+			offset: size + headerSize + int64(len(key)),
+			size:   uint32(len(value)),
+		}
+		size += int64(len(buf))
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		// the old hint doesn't match the new data file.
+		if err = os.Remove(t.path + ".hint"); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, t.path+".data")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	t.file.Close()
+	if t.file, err = os.OpenFile(t.path+".data", os.O_RDWR, 0644); err != nil {
+		return err
+	}
+	t.index, t.size, t.dead = index, size, 0
+	if err = t.writeHint(); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(t.path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// close writes the hint file and closes the data file.
+func (t *table) close() error {
+	err := t.writeHint()
+	if e := t.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
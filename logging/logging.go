@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/WindomZ/gkv"
+)
+
+// Options are the options of the logging layer.
+type Options struct {
+	// Logger logs the events, slog.Default() if nil.
+	Logger *slog.Logger
+	// Level is the level of the operations,
+	// the slow ones are logged at Warn and the failed ones at Error.
+	Level slog.Level
+	// SlowThreshold is the duration above which an operation is slow,
+	// zero disables it.
+	SlowThreshold time.Duration
+	// Key returns the value of a key logged, so that secrets in keys
+	// don't leak into logs, HashKey if nil.
+	Key func([]byte) slog.Value
+}
+
+// DefaultOptions are the options used by Wrap.
+var DefaultOptions = Options{
+	Level:         slog.LevelDebug,
+	SlowThreshold: 100 * time.Millisecond,
+	Key:           HashKey,
+}
+
+// HashKey logs a key by the first 8 bytes of its SHA-256 in hex,
+// which tells the keys apart without revealing them.
+func HashKey(key []byte) slog.Value {
+	sum := sha256.Sum256(key)
+	return slog.StringValue(hex.EncodeToString(sum[:8]))
+}
+
+// RedactKey logs a key as REDACTED.
+func RedactKey([]byte) slog.Value {
+	return slog.StringValue("REDACTED")
+}
+
+// RawKey logs a key as it is.
+func RawKey(key []byte) slog.Value {
+	return slog.StringValue(string(key))
+}
+
+// options returns opts with the defaults filled in.
+func (opts Options) options() Options {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Key == nil {
+		opts.Key = HashKey
+	}
+	return opts
+}
+
+// Middleware returns a gkv.Middleware logging every operation by opts.
+func Middleware(opts Options) gkv.Middleware {
+	opts = opts.options()
+	return func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			start := time.Now()
+			err := next(c)
+			d := time.Since(start)
+
+			level := opts.Level
+			slow := opts.SlowThreshold > 0 && d > opts.SlowThreshold
+			if err != nil {
+				level = slog.LevelError
+			} else if slow && level < slog.LevelWarn {
+				level = slog.LevelWarn
+			}
+			ctx := c.Ctx
+			if !opts.Logger.Enabled(ctx, level) {
+				return err
+			}
+
+			attrs := []slog.Attr{
+				slog.String("driver", c.Driver),
+				slog.String("table", string(c.Table)),
+			}
+			switch c.Op {
+			case gkv.OpPut, gkv.OpGet:
+				attrs = append(attrs,
+					slog.Attr{Key: "key", Value: opts.Key(c.Key)},
+					slog.Int("value_size", len(c.Value)))
+			case gkv.OpDelete, gkv.OpPrefix:
+				attrs = append(attrs, slog.Attr{Key: "key", Value: opts.Key(c.Key)})
+			case gkv.OpCount:
+				attrs = append(attrs, slog.Int("count", c.Count))
+			case gkv.OpWrite:
+				attrs = append(attrs, slog.Int("batch", c.Batch.Len()))
+			}
+			attrs = append(attrs, slog.Duration("duration", d))
+			if slow {
+				attrs = append(attrs, slog.Bool("slow", true))
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Any("err", err))
+			}
+			opts.Logger.LogAttrs(ctx, level, "gkv "+c.Op.String(), attrs...)
+			return err
+		}
+	}
+}
+
+// KV is a layer logging the operations of any KV,
+// and its registers, compactions and close at Info.
+type KV struct {
+	gkv.KV
+	opts   Options
+	driver string
+}
+
+// Wrap returns kv with its events logged by opts.
+func Wrap(opts Options, kv gkv.KV) *KV {
+	opts = opts.options()
+	w := &KV{
+		KV:     gkv.Wrap(kv, Middleware(opts)),
+		opts:   opts,
+		driver: gkv.DriverName(kv),
+	}
+	w.log("gkv open", nil)
+	return w
+}
+
+// Unwrap returns the KV the layer is on.
+func (kv *KV) Unwrap() gkv.KV {
+	return kv.KV
+}
+
+// log logs an event of the KV at Info, or at Error if it failed.
+func (kv *KV) log(msg string, err error, attrs ...slog.Attr) {
+	level := slog.LevelInfo
+	attrs = append([]slog.Attr{slog.String("driver", kv.driver)}, attrs...)
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("err", err))
+	}
+	kv.opts.Logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// Close releases all database resources.
+func (kv *KV) Close() error {
+	err := kv.KV.Close()
+	kv.log("gkv close", err)
+	return err
+}
+
+// Register initializes a new database if it doesn't already exist.
+func (kv *KV) Register(table []byte) error {
+	err := kv.KV.Register(table)
+	kv.log("gkv register", err, slog.String("table", string(table)))
+	return err
+}
+
+// Compact reclaims the unused space of the storage.
+func (kv *KV) Compact() error {
+	start := time.Now()
+	err := kv.KV.Compact()
+	kv.log("gkv compact", err, slog.Duration("duration", time.Since(start)))
+	return err
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix.
+func (kv *KV) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	return gkv.PrefixOf(kv.KV, prefix, f)
+}
+
+// Write writes all the writes of the batch, atomically if the wrapped KV is a Batcher.
+func (kv *KV) Write(b *gkv.Batch) error {
+	return b.Apply(kv.KV)
+}
+
+// WithContext returns a copy of the KV whose operations carry ctx.
+func (kv *KV) WithContext(ctx context.Context) gkv.KV {
+	c := *kv
+	c.KV = gkv.WithContext(ctx, kv.KV)
+	return &c
+}
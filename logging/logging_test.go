@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+var errDemo = errors.New("demo")
+
+// records returns the JSON records logged into buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var rs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &r))
+		rs = append(rs, r)
+	}
+	buf.Reset()
+	return rs
+}
+
+func newLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestWrap(t *testing.T) {
+	var buf bytes.Buffer
+	opts := DefaultOptions
+	opts.Logger = newLogger(&buf)
+	kv := Wrap(opts, memory.Open())
+	assert.NoError(t, kv.Register([]byte("table")))
+
+	rs := records(t, &buf)
+	assert.Len(t, rs, 2)
+	assert.Equal(t, "gkv open", rs[0]["msg"])
+	assert.Equal(t, "memory", rs[0]["driver"])
+	assert.Equal(t, "gkv register", rs[1]["msg"])
+	assert.Equal(t, "table", rs[1]["table"])
+
+	assert.NoError(t, kv.Put([]byte("secret"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("secret")))
+	assert.Equal(t, 1, kv.Count())
+	assert.Equal(t, errDemo, kv.Iterator(func(k, v []byte) error {
+		return errDemo
+	}))
+	assert.False(t, strings.Contains(buf.String(), "secret"))
+
+	rs = records(t, &buf)
+	assert.Len(t, rs, 4)
+	assert.Equal(t, "gkv put", rs[0]["msg"])
+	assert.Equal(t, "DEBUG", rs[0]["level"])
+	assert.Equal(t, HashKey([]byte("secret")).String(), rs[0]["key"])
+	assert.Equal(t, float64(5), rs[0]["value_size"])
+	assert.Equal(t, "gkv get", rs[1]["msg"])
+	assert.Equal(t, float64(1), rs[2]["count"])
+	assert.Equal(t, "ERROR", rs[3]["level"])
+	assert.Equal(t, "demo", rs[3]["err"])
+
+	b := new(gkv.Batch)
+	b.Put([]byte("key2"), []byte("value2"))
+	assert.NoError(t, kv.Write(b))
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("key"), func(k, v []byte) error { return nil }))
+	assert.NoError(t, kv.Compact())
+	assert.NoError(t, kv.Close())
+
+	rs = records(t, &buf)
+	assert.Len(t, rs, 4)
+	assert.Equal(t, float64(1), rs[0]["batch"])
+	assert.Equal(t, "gkv prefix", rs[1]["msg"])
+	assert.Equal(t, "gkv compact", rs[2]["msg"])
+	assert.Equal(t, "INFO", rs[2]["level"])
+	assert.Equal(t, "gkv close", rs[3]["msg"])
+}
+
+func TestSlow(t *testing.T) {
+	var buf bytes.Buffer
+	sleep := func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			time.Sleep(2 * time.Millisecond)
+			return next(c)
+		}
+	}
+	kv := gkv.Wrap(memory.Open(), Middleware(Options{
+		Logger:        newLogger(&buf),
+		Level:         slog.LevelDebug,
+		SlowThreshold: time.Millisecond,
+		Key:           RawKey,
+	}), sleep)
+	kv.Get([]byte("key"))
+
+	rs := records(t, &buf)
+	assert.Len(t, rs, 1)
+	assert.Equal(t, "WARN", rs[0]["level"])
+	assert.Equal(t, true, rs[0]["slow"])
+	assert.Equal(t, "key", rs[0]["key"])
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	kv := gkv.Wrap(memory.Open(), Middleware(Options{Logger: logger, Level: slog.LevelDebug, Key: RedactKey}))
+	kv.Put([]byte("key"), []byte("value"))
+	assert.Equal(t, 0, buf.Len())
+
+	kv = gkv.Wrap(memory.Open(), Middleware(Options{Logger: logger, Key: RedactKey}))
+	kv.Put([]byte("key"), []byte("value"))
+	rs := records(t, &buf)
+	assert.Len(t, rs, 1)
+	assert.Equal(t, "INFO", rs[0]["level"])
+	assert.Equal(t, "REDACTED", rs[0]["key"])
+}
@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/WindomZ/gkv"
+	"github.com/google/btree"
+)
+
+// item is a key-value pair ordered by the key.
+type item struct {
+	key, value []byte
+}
+
+// Less implements btree.Item.
+func (i item) Less(than btree.Item) bool {
+	return bytes.Compare(i.key, than.(item).key) < 0
+}
+
+// KV is an in-memory adapter backed by google/btree,
+// everything is lost once it is closed.
+type KV struct {
+	mu     sync.Mutex
+	tables map[string]*btree.BTree
+	table  string
+}
+
+// Open creates a new in-memory driver, paths are ignored.
+func Open(paths ...string) gkv.KV {
+	return &KV{
+		tables: map[string]*btree.BTree{
+			gkv.DefaultTableName: btree.New(32),
+		},
+		table: gkv.DefaultTableName,
+	}
+}
+
+// DB returns the native DB of the adapter,
+// which is the map of table names to the trees.
+func (kv *KV) DB() interface{} {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.tables
+}
+
+// Close releases all database resources.
+func (kv *KV) Close() error {
+	kv.mu.Lock()
+	kv.tables = make(map[string]*btree.BTree)
+	kv.mu.Unlock()
+	return nil
+}
+
+// Register initializes a new database if it doesn't already exist.
+func (kv *KV) Register(table []byte) error {
+	if len(table) == 0 {
+		return gkv.ErrTableName
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.table = string(table)
+	if _, ok := kv.tables[kv.table]; !ok {
+		kv.tables[kv.table] = btree.New(32)
+	}
+	return nil
+}
+
+// tree returns the tree of the registered table.
+func (kv *KV) tree() *btree.BTree {
+	t, ok := kv.tables[kv.table]
+	if !ok {
+		t = btree.New(32)
+		kv.tables[kv.table] = t
+	}
+	return t
+}
+
+// Put sets the value for a key.
+func (kv *KV) Put(key, value []byte) error {
+	i := item{
+		key:   append([]byte{}, key...),
+		value: append([]byte{}, value...),
+	}
+	kv.mu.Lock()
+	kv.tree().ReplaceOrInsert(i)
+	kv.mu.Unlock()
+	return nil
+}
+
+// Get retrieves the value for a key.
+func (kv *KV) Get(key []byte) []byte {
+	kv.mu.Lock()
+	i := kv.tree().Get(item{key: key})
+	kv.mu.Unlock()
+	if i == nil {
+		return nil
+	}
+	return append([]byte{}, i.(item).value...)
+}
+
+// Delete deletes the given key from the database resources.
+func (kv *KV) Delete(key []byte) error {
+	kv.mu.Lock()
+	kv.tree().Delete(item{key: key})
+	kv.mu.Unlock()
+	return nil
+}
+
+// Write writes all the writes of the batch atomically.
+func (kv *KV) Write(b *gkv.Batch) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	t := kv.tree()
+	return b.Replay(func(key, value []byte) error {
+		t.ReplaceOrInsert(item{
+			key:   append([]byte{}, key...),
+			value: append([]byte{}, value...),
+		})
+		return nil
+	}, func(key []byte) error {
+		t.Delete(item{key: key})
+		return nil
+	})
+}
+
+// Count returns the total number of all the keys.
+func (kv *KV) Count() int {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.tree().Len()
+}
+
+// Iterator creates an iterator for iterating over all the keys in order.
+// It iterates over a snapshot, so the callback may modify the database,
+// and the keys and values passed to it are copies.
+func (kv *KV) Iterator(f func([]byte, []byte) error) (err error) {
+	kv.mu.Lock()
+	snapshot := kv.tree().Clone()
+	kv.mu.Unlock()
+	snapshot.Ascend(func(i btree.Item) bool {
+		err = f(append([]byte{}, i.(item).key...), append([]byte{}, i.(item).value...))
+		return err == nil
+	})
+	if err == gkv.ErrStopIteration {
+		return nil
+	}
+	return
+}
+
+// SetSync sets whether every write is flushed to stable storage,
+// there is no stable storage in memory.
+func (kv *KV) SetSync(sync bool) {
+}
+
+// Sync flushes all pending writes to stable storage,
+// there is no stable storage in memory.
+func (kv *KV) Sync() error {
+	return nil
+}
+
+// Compact reclaims the unused space of the storage,
+// the trees never hold deleted keys.
+func (kv *KV) Compact() error {
+	return nil
+}
+
+// Stats returns the statistics of the storage,
+// the size is the one of the keys and values of all the tables.
+func (kv *KV) Stats() gkv.Stats {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	var size int64
+	for _, t := range kv.tables {
+		t.Ascend(func(i btree.Item) bool {
+			size += int64(len(i.(item).key) + len(i.(item).value))
+			return true
+		})
+	}
+	return gkv.Stats{
+		Keys: kv.tree().Len(),
+		Size: size,
+		Backend: map[string]interface{}{
+			"tables": len(kv.tables),
+		},
+	}
+}
+
+func init() {
+	gkv.Register(Open)
+}
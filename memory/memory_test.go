@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demo *KV
+var (
+	demoTable = []byte("table-表_1 2%3")
+	demoKey   = []byte("key-键_4 5%6")
+	demoValue = []byte("value-值_7 8%9")
+)
+
+func TestOpen(t *testing.T) {
+	db := Open()
+	if v, ok := db.(*KV); ok {
+		demo = v
+	}
+}
+
+func TestDB(t *testing.T) {
+	assert.NotEmpty(t, demo.DB())
+}
+
+func TestRegister(t *testing.T) {
+	assert.NoError(t, demo.Register(demoTable))
+}
+
+func TestPut(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestIterator(t *testing.T) {
+	cnt := 0
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		cnt++
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		k[0], v[0] = 'K', 'V'
+		return nil
+	}))
+	assert.Equal(t, 1, cnt)
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestTables(t *testing.T) {
+	other := []byte("other")
+	assert.NoError(t, demo.Register(other))
+	assert.Equal(t, 0, demo.Count())
+	assert.Nil(t, demo.Get(demoKey))
+
+	for _, k := range []string{"c", "a", "b"} {
+		assert.NoError(t, demo.Put([]byte(k), demoValue))
+	}
+	var keys []string
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		keys = append(keys, string(k))
+		return demo.Delete(k)
+	}))
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, 0, demo.Count())
+
+	assert.NoError(t, demo.Register(demoTable))
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+}
+
+func TestWrite(t *testing.T) {
+	b := new(gkv.Batch)
+	b.Put([]byte("batch1"), demoValue)
+	b.Put([]byte("batch2"), demoValue)
+	b.Delete([]byte("batch1"))
+	assert.NoError(t, demo.Write(b))
+	assert.Nil(t, demo.Get([]byte("batch1")))
+	assert.Equal(t, demoValue, demo.Get([]byte("batch2")))
+
+	// the other calls see the whole batch or none of it.
+	b.Reset()
+	for i := 0; i < 100; i++ {
+		b.Put([]byte(fmt.Sprintf("batch%03d", i)), demoValue)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, demo.Write(b))
+	}()
+	for n := demo.Count(); n != 102; n = demo.Count() {
+		assert.Equal(t, 2, n)
+	}
+	<-done
+
+	b.Reset()
+	for i := 0; i < 100; i++ {
+		b.Delete([]byte(fmt.Sprintf("batch%03d", i)))
+	}
+	b.Delete([]byte("batch2"))
+	assert.NoError(t, demo.Write(b))
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestDelete(t *testing.T) {
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.Equal(t, 0, demo.Count())
+}
+
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Equal(t, int64(len(demoKey)+len(demoValue)), s.Size)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestClose(t *testing.T) {
+	assert.NoError(t, demo.Close())
+}
@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WindomZ/gkv"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms.
+var DefaultBuckets = []float64{
+	.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5,
+}
+
+// labels identify the series of an operation.
+type labels struct {
+	driver, table, op string
+}
+
+// series are the counters and the latency histogram of an operation.
+type series struct {
+	count   uint64
+	errors  uint64
+	sum     float64
+	buckets []uint64
+}
+
+// Metrics counts the operations, the errors and the latencies of KVs,
+// labelled by the driver, the table and the operation.
+type Metrics struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[labels]*series
+}
+
+// New returns a new Metrics with the latency histograms of buckets,
+// which are DefaultBuckets if none.
+func New(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		buckets: buckets,
+		series:  make(map[labels]*series),
+	}
+}
+
+// Middleware returns a gkv.Middleware observing every operation.
+func (m *Metrics) Middleware() gkv.Middleware {
+	return func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			start := time.Now()
+			err := next(c)
+			m.observe(labels{
+				driver: c.Driver,
+				table:  string(c.Table),
+				op:     c.Op.String(),
+			}, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Wrap returns kv with its operations observed.
+func (m *Metrics) Wrap(kv gkv.KV) gkv.KV {
+	return gkv.Wrap(kv, m.Middleware())
+}
+
+// observe records an operation which took d and failed with err.
+func (m *Metrics) observe(l labels, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[l]
+	if !ok {
+		s = &series{buckets: make([]uint64, len(m.buckets))}
+		m.series[l] = s
+	}
+	s.count++
+	if err != nil {
+		s.errors++
+	}
+	seconds := d.Seconds()
+	s.sum += seconds
+	for i, le := range m.buckets {
+		if seconds <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// snapshot returns the labels in order and a copy of their series.
+func (m *Metrics) snapshot() ([]labels, map[labels]series) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]labels, 0, len(m.series))
+	values := make(map[labels]series, len(m.series))
+	for l, s := range m.series {
+		keys = append(keys, l)
+		s.buckets = append([]uint64{}, s.buckets...)
+		values[l] = *s
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].driver != keys[j].driver {
+			return keys[i].driver < keys[j].driver
+		} else if keys[i].table != keys[j].table {
+			return keys[i].table < keys[j].table
+		}
+		return keys[i].op < keys[j].op
+	})
+	return keys, values
+}
+
+// escaper escapes the label values of the text exposition format.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// format returns the labels in the text exposition format.
+func (l labels) format(extra string) string {
+	s := fmt.Sprintf(`driver="%s",table="%s",op="%s"`,
+		escaper.Replace(l.driver), escaper.Replace(l.table), escaper.Replace(l.op))
+	if extra != "" {
+		s += "," + extra
+	}
+	return "{" + s + "}"
+}
+
+// float returns v in the text exposition format.
+func float(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteTo writes all the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	keys, values := m.snapshot()
+	cw := &countWriter{w: bufio.NewWriter(w)}
+
+	fmt.Fprintln(cw, "# HELP gkv_operations_total Total number of KV operations.")
+	fmt.Fprintln(cw, "# TYPE gkv_operations_total counter")
+	for _, l := range keys {
+		fmt.Fprintf(cw, "gkv_operations_total%s %d\n", l.format(""), values[l].count)
+	}
+	fmt.Fprintln(cw, "# HELP gkv_operation_errors_total Total number of failed KV operations.")
+	fmt.Fprintln(cw, "# TYPE gkv_operation_errors_total counter")
+	for _, l := range keys {
+		fmt.Fprintf(cw, "gkv_operation_errors_total%s %d\n", l.format(""), values[l].errors)
+	}
+	fmt.Fprintln(cw, "# HELP gkv_operation_duration_seconds Latency of KV operations.")
+	fmt.Fprintln(cw, "# TYPE gkv_operation_duration_seconds histogram")
+	for _, l := range keys {
+		s := values[l]
+		for i, le := range m.buckets {
+			fmt.Fprintf(cw, "gkv_operation_duration_seconds_bucket%s %d\n",
+				l.format(`le="`+float(le)+`"`), s.buckets[i])
+		}
+		fmt.Fprintf(cw, "gkv_operation_duration_seconds_bucket%s %d\n", l.format(`le="+Inf"`), s.count)
+		fmt.Fprintf(cw, "gkv_operation_duration_seconds_sum%s %s\n", l.format(""), float(s.sum))
+		fmt.Fprintf(cw, "gkv_operation_duration_seconds_count%s %d\n", l.format(""), s.count)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// countWriter counts the bytes written and keeps the first error.
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
+
+// ServeHTTP serves all the metrics in the Prometheus text exposition format,
+// so that a Metrics is a handler of a /metrics endpoint.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Stats are the metrics of an operation published by expvar.
+type Stats struct {
+	Count   uint64  `json:"count"`
+	Errors  uint64  `json:"errors"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Stats returns the metrics of the operations by the driver, the table and the operation.
+func (m *Metrics) Stats() map[string]map[string]map[string]Stats {
+	keys, values := m.snapshot()
+	stats := make(map[string]map[string]map[string]Stats)
+	for _, l := range keys {
+		if stats[l.driver] == nil {
+			stats[l.driver] = make(map[string]map[string]Stats)
+		}
+		if stats[l.driver][l.table] == nil {
+			stats[l.driver][l.table] = make(map[string]Stats)
+		}
+		s := values[l]
+		stats[l.driver][l.table][l.op] = Stats{
+			Count:   s.count,
+			Errors:  s.errors,
+			Seconds: s.sum,
+		}
+	}
+	return stats
+}
+
+// Publish publishes Stats by expvar under the name,
+// it panics if the name is already published, as expvar.Publish does.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return m.Stats()
+	}))
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+	"github.com/prometheus/common/expfmt"
+)
+
+var errDemo = errors.New("demo")
+
+func demo(t *testing.T) *Metrics {
+	m := New()
+	kv := m.Wrap(memory.Open())
+	assert.NoError(t, kv.Register([]byte(`table "1"`)))
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("missing")))
+	assert.Equal(t, errDemo, kv.Iterator(func(k, v []byte) error {
+		return errDemo
+	}))
+	return m
+}
+
+func TestWriteTo(t *testing.T) {
+	m := demo(t)
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, families, 3)
+
+	ops := make(map[string]float64)
+	for _, metric := range families["gkv_operations_total"].GetMetric() {
+		labels := make(map[string]string)
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "memory", labels["driver"])
+		assert.Equal(t, `table "1"`, labels["table"])
+		ops[labels["op"]] = metric.GetCounter().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"put": 1, "get": 2, "iterator": 1}, ops)
+
+	for _, metric := range families["gkv_operation_errors_total"].GetMetric() {
+		for _, l := range metric.GetLabel() {
+			if l.GetName() == "op" && l.GetValue() == "iterator" {
+				assert.Equal(t, float64(1), metric.GetCounter().GetValue())
+			}
+		}
+	}
+	for _, metric := range families["gkv_operation_duration_seconds"].GetMetric() {
+		h := metric.GetHistogram()
+		assert.Len(t, h.GetBucket(), len(DefaultBuckets)+1)
+		assert.True(t, h.GetSampleCount() >= 1)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	demo(t).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.True(t, strings.Contains(w.Body.String(),
+		`gkv_operations_total{driver="memory",table="table \"1\"",op="get"} 2`))
+}
+
+func TestPublish(t *testing.T) {
+	demo(t).Publish("gkv_test")
+	var stats map[string]map[string]map[string]Stats
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get("gkv_test").String()), &stats))
+	s := stats["memory"][`table "1"`]
+	assert.Equal(t, uint64(2), s["get"].Count)
+	assert.Equal(t, uint64(1), s["iterator"].Errors)
+	assert.True(t, s["put"].Seconds > 0)
+
+	assert.Panics(t, func() { New().Publish("gkv_test") })
+}
+
+func TestBuckets(t *testing.T) {
+	m := New(1, 0.001)
+	assert.Equal(t, []float64{0.001, 1}, m.buckets)
+	kv := gkv.Wrap(memory.Open(), m.Middleware())
+	kv.Count()
+	_, values := m.snapshot()
+	s := values[labels{driver: "memory", table: gkv.DefaultTableName, op: "count"}]
+	assert.Equal(t, []uint64{1, 1}, s.buckets)
+}
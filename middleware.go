@@ -0,0 +1,326 @@
+package gkv
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrReadOnly write to a read-only KV error
+var ErrReadOnly = errors.New("read-only")
+
+// ErrKeySize illegal key size error
+var ErrKeySize = errors.New("illegal key size")
+
+// Op is an operation of a KV intercepted by middlewares.
+type Op int
+
+// The operations intercepted by middlewares.
+const (
+	OpPut Op = iota + 1
+	OpGet
+	OpDelete
+	OpCount
+	OpIterator
+	OpPrefix
+	OpWrite
+)
+
+var opNames = map[Op]string{
+	OpPut:      "put",
+	OpGet:      "get",
+	OpDelete:   "delete",
+	OpCount:    "count",
+	OpIterator: "iterator",
+	OpPrefix:   "prefix",
+	OpWrite:    "write",
+}
+
+// String returns the name of the operation.
+func (o Op) String() string {
+	return opNames[o]
+}
+
+// Call is an operation passed through the middlewares.
+type Call struct {
+	// Ctx is the context of the operation, see WithContext,
+	// which a middleware may replace for the next ones.
+	Ctx context.Context
+	// Op is the operation.
+	Op Op
+	// Driver is the name of the adapter, see DriverName.
+	Driver string
+	// Table is the name of the table registered.
+	Table []byte
+	// Key is the key of Put, Get and Delete, or the prefix of Prefix.
+	Key []byte
+	// Value is the value of Put, or the value got by Get.
+	Value []byte
+	// Count is the number of the keys counted by Count.
+	Count int
+	// Batch is the batch of Write.
+	Batch *Batch
+	// Iter is the callback of Iterator and Prefix,
+	// which a middleware may wrap to intercept every key.
+	Iter func([]byte, []byte) error
+}
+
+// Handler performs a Call.
+type Handler func(*Call) error
+
+// Middleware wraps a Handler into another one, like http middlewares,
+// it may change the Call, return early or act on the result of next.
+type Middleware func(next Handler) Handler
+
+// Contexter is implemented by the KVs which carry a context into their operations,
+// such as the KVs returned by Wrap.
+type Contexter interface {
+	// WithContext returns a copy of the KV whose operations carry ctx.
+	WithContext(context.Context) KV
+}
+
+// WithContext returns a copy of kv whose operations carry ctx into the middlewares,
+// such as for tracing, and fail with the error of ctx once it is done.
+// It returns kv as it is unless kv is a Contexter.
+func WithContext(ctx context.Context, kv KV) KV {
+	if c, ok := kv.(Contexter); ok {
+		return c.WithContext(ctx)
+	}
+	return kv
+}
+
+// Unwrapper is implemented by the KVs layered on another KV,
+// such as the KVs returned by Wrap, compress and encrypt.
+type Unwrapper interface {
+	// Unwrap returns the KV the layer is on.
+	Unwrap() KV
+}
+
+// DriverName returns the name of the adapter of kv under all its layers,
+// which is the last element of the package path of its type.
+func DriverName(kv KV) string {
+	for {
+		u, ok := kv.(Unwrapper)
+		if !ok {
+			break
+		}
+		kv = u.Unwrap()
+	}
+	t := reflect.TypeOf(kv)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return path.Base(t.PkgPath())
+}
+
+// wrapped is a KV whose operations pass through the middlewares.
+type wrapped struct {
+	KV
+	driver  string
+	handler Handler
+	ctx     context.Context
+	table   *table
+}
+
+// table is the table registered, shared by the KVs of WithContext.
+type table struct {
+	mu   sync.RWMutex
+	name []byte
+}
+
+// Wrap returns kv with its operations passed through the middlewares,
+// the first middleware is the outermost one.
+// Get returns nil and Count returns 0 if a middleware fails the Call.
+func Wrap(kv KV, mws ...Middleware) KV {
+	w := &wrapped{
+		KV:     kv,
+		driver: DriverName(kv),
+		ctx:    context.Background(),
+		table:  &table{name: []byte(DefaultTableName)},
+	}
+	w.handler = w.do
+	for i := len(mws) - 1; i >= 0; i-- {
+		w.handler = mws[i](w.handler)
+	}
+	return w
+}
+
+// do performs the Call on the wrapped KV, unless the context is done.
+// The context is always carried into the wrapped KV, as it may be replaced
+// by a middleware and contexts are not always comparable.
+func (w *wrapped) do(c *Call) (err error) {
+	if err = c.Ctx.Err(); err != nil {
+		return
+	}
+	kv := WithContext(c.Ctx, w.KV)
+	switch c.Op {
+	case OpPut:
+		err = kv.Put(c.Key, c.Value)
+	case OpGet:
+		c.Value = kv.Get(c.Key)
+	case OpDelete:
+		err = kv.Delete(c.Key)
+	case OpCount:
+		c.Count = kv.Count()
+	case OpIterator:
+		err = kv.Iterator(c.Iter)
+	case OpPrefix:
+		err = PrefixOf(kv, c.Key, c.Iter)
+	case OpWrite:
+		err = c.Batch.Apply(kv)
+	}
+	return
+}
+
+// Unwrap returns the KV passed to Wrap.
+func (w *wrapped) Unwrap() KV {
+	return w.KV
+}
+
+// WithContext returns a copy of the KV whose Calls carry ctx.
+func (w *wrapped) WithContext(ctx context.Context) KV {
+	c := *w
+	c.ctx = ctx
+	return &c
+}
+
+// call returns a new Call of the operation.
+func (w *wrapped) call(op Op) *Call {
+	w.table.mu.RLock()
+	defer w.table.mu.RUnlock()
+	return &Call{
+		Ctx:    w.ctx,
+		Op:     op,
+		Driver: w.driver,
+		Table:  w.table.name,
+	}
+}
+
+// Register initializes a new database if it doesn't already exist.
+func (w *wrapped) Register(table []byte) error {
+	if err := w.KV.Register(table); err != nil {
+		return err
+	}
+	w.table.mu.Lock()
+	w.table.name = append([]byte{}, table...)
+	w.table.mu.Unlock()
+	return nil
+}
+
+// Put sets the value for a key.
+func (w *wrapped) Put(key, value []byte) error {
+	c := w.call(OpPut)
+	c.Key, c.Value = key, value
+	return w.handler(c)
+}
+
+// Get retrieves the value for a key.
+func (w *wrapped) Get(key []byte) []byte {
+	c := w.call(OpGet)
+	c.Key = key
+	if w.handler(c) != nil {
+		return nil
+	}
+	return c.Value
+}
+
+// Delete deletes the given key from the database resources.
+func (w *wrapped) Delete(key []byte) error {
+	c := w.call(OpDelete)
+	c.Key = key
+	return w.handler(c)
+}
+
+// Count returns the total number of all the keys.
+func (w *wrapped) Count() int {
+	c := w.call(OpCount)
+	if w.handler(c) != nil {
+		return 0
+	}
+	return c.Count
+}
+
+// Iterator creates an iterator for iterating over all the keys.
+func (w *wrapped) Iterator(f func([]byte, []byte) error) error {
+	c := w.call(OpIterator)
+	c.Iter = f
+	return w.handler(c)
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix.
+func (w *wrapped) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	c := w.call(OpPrefix)
+	c.Key, c.Iter = prefix, f
+	return w.handler(c)
+}
+
+// Write writes all the writes of the batch, atomically if the wrapped KV is a Batcher.
+func (w *wrapped) Write(b *Batch) error {
+	c := w.call(OpWrite)
+	c.Batch = b
+	return w.handler(c)
+}
+
+// ReadOnly returns a Middleware failing all the writes with ErrReadOnly.
+func ReadOnly() Middleware {
+	return func(next Handler) Handler {
+		return func(c *Call) error {
+			switch c.Op {
+			case OpPut, OpDelete, OpWrite:
+				return ErrReadOnly
+			}
+			return next(c)
+		}
+	}
+}
+
+// ValidateKey returns a Middleware failing the operations of the keys
+// for which f returns an error, including the keys in batches.
+func ValidateKey(f func([]byte) error) Middleware {
+	return func(next Handler) Handler {
+		return func(c *Call) error {
+			switch c.Op {
+			case OpPut, OpGet, OpDelete:
+				if err := f(c.Key); err != nil {
+					return err
+				}
+			case OpWrite:
+				if err := c.Batch.Replay(func(key, _ []byte) error {
+					return f(key)
+				}, f); err != nil {
+					return err
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// MaxKeySize returns a Middleware failing the operations of the keys
+// which are empty or longer than n bytes with ErrKeySize.
+func MaxKeySize(n int) Middleware {
+	return ValidateKey(func(key []byte) error {
+		if len(key) == 0 || len(key) > n {
+			return ErrKeySize
+		}
+		return nil
+	})
+}
+
+// Logger returns a Middleware logging every operation with its duration
+// and error, the keys are logged by their sizes only.
+func Logger(l *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(c *Call) error {
+			start := time.Now()
+			err := next(c)
+			l.Printf("gkv: %s %s/%s key=%dB %v err=%v",
+				c.Op, c.Driver, c.Table, len(c.Key), time.Since(start), err)
+			return err
+		}
+	}
+}
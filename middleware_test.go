@@ -0,0 +1,162 @@
+package gkv_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	var ops []string
+	trace := func(name string) gkv.Middleware {
+		return func(next gkv.Handler) gkv.Handler {
+			return func(c *gkv.Call) error {
+				ops = append(ops, name+":"+c.Op.String())
+				return next(c)
+			}
+		}
+	}
+	kv := gkv.Wrap(memory.Open(), trace("outer"), trace("inner"))
+	assert.Equal(t, "memory", gkv.DriverName(kv))
+	assert.Equal(t, "memory", gkv.DriverName(gkv.Wrap(kv)))
+
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Equal(t, 1, kv.Count())
+	assert.Equal(t, []string{
+		"outer:put", "inner:put",
+		"outer:get", "inner:get",
+		"outer:count", "inner:count",
+	}, ops)
+
+	ops = nil
+	b := new(gkv.Batch)
+	b.Put([]byte("key2"), []byte("value2"))
+	assert.NoError(t, gkv.Wrap(kv, trace("wrap")).(gkv.Batcher).Write(b))
+	assert.NoError(t, kv.Iterator(func(k, v []byte) error { return nil }))
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("key"), func(k, v []byte) error { return nil }))
+	assert.NoError(t, kv.Delete([]byte("key")))
+	assert.Equal(t, []string{
+		"wrap:write", "outer:write", "inner:write",
+		"outer:iterator", "inner:iterator",
+		"outer:prefix", "inner:prefix",
+		"outer:delete", "inner:delete",
+	}, ops)
+}
+
+func TestIntercept(t *testing.T) {
+	var keys []string
+	upper := func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			if c.Op == gkv.OpIterator {
+				f := c.Iter
+				c.Iter = func(k, v []byte) error {
+					keys = append(keys, string(c.Table)+"/"+string(k))
+					return f(k, bytes.ToUpper(v))
+				}
+			}
+			err := next(c)
+			if c.Op == gkv.OpGet && c.Value != nil {
+				c.Value = bytes.ToUpper(c.Value)
+			}
+			return err
+		}
+	}
+	kv := gkv.Wrap(memory.Open(), upper)
+	assert.NoError(t, kv.Register([]byte("table")))
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("VALUE"), kv.Get([]byte("key")))
+	assert.NoError(t, kv.Iterator(func(k, v []byte) error {
+		assert.Equal(t, []byte("VALUE"), v)
+		return nil
+	}))
+	assert.Equal(t, []string{"table/key"}, keys)
+}
+
+func TestReadOnly(t *testing.T) {
+	db := memory.Open()
+	assert.NoError(t, db.Put([]byte("key"), []byte("value")))
+
+	kv := gkv.Wrap(db, gkv.ReadOnly())
+	assert.Equal(t, gkv.ErrReadOnly, kv.Put([]byte("key"), []byte("value2")))
+	assert.Equal(t, gkv.ErrReadOnly, kv.Delete([]byte("key")))
+	assert.Equal(t, gkv.ErrReadOnly, kv.(gkv.Batcher).Write(new(gkv.Batch)))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Equal(t, 1, kv.Count())
+}
+
+func TestMaxKeySize(t *testing.T) {
+	kv := gkv.Wrap(memory.Open(), gkv.MaxKeySize(4))
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, gkv.ErrKeySize, kv.Put([]byte("key12"), []byte("value")))
+	assert.Equal(t, gkv.ErrKeySize, kv.Delete(nil))
+	assert.Nil(t, kv.Get([]byte("key12")))
+
+	b := new(gkv.Batch)
+	b.Put([]byte("key2"), []byte("value"))
+	b.Delete([]byte("key123"))
+	assert.Equal(t, gkv.ErrKeySize, kv.(gkv.Batcher).Write(b))
+	assert.Equal(t, 1, kv.Count())
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	kv := gkv.Wrap(memory.Open(), gkv.Logger(log.New(&buf, "", 0)), gkv.ReadOnly())
+	assert.Equal(t, gkv.ErrReadOnly, kv.Put([]byte("secret"), []byte("value")))
+	assert.Nil(t, kv.Get([]byte("secret")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "gkv: put memory/gkv key=6B "))
+	assert.True(t, strings.HasSuffix(lines[0], "err=read-only"))
+	assert.True(t, strings.HasSuffix(lines[1], "err=<nil>"))
+	assert.False(t, strings.Contains(buf.String(), "secret"))
+}
+
+func TestWithContext(t *testing.T) {
+	type key struct{}
+	var values []interface{}
+	trace := func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			values = append(values, c.Ctx.Value(key{}))
+			return next(c)
+		}
+	}
+	db := memory.Open()
+	assert.Equal(t, db, gkv.WithContext(context.Background(), db))
+
+	kv := gkv.Wrap(gkv.Wrap(db, trace), trace)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.NoError(t, gkv.WithContext(ctx, kv).Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []interface{}{nil, nil, "value", "value"}, values)
+
+	// the copies share the table registered.
+	assert.NoError(t, gkv.WithContext(ctx, kv).Register([]byte("table")))
+	assert.Equal(t, 0, kv.Count())
+
+	cancel()
+	assert.Equal(t, context.Canceled, gkv.WithContext(ctx, kv).Delete([]byte("key")))
+	assert.Nil(t, gkv.WithContext(ctx, kv).Get([]byte("key")))
+}
+
+// sliceContext is a context whose type is not comparable.
+type sliceContext struct {
+	context.Context
+	values []string
+}
+
+func TestWithContextUncomparable(t *testing.T) {
+	ctx := sliceContext{Context: context.Background(), values: []string{"value"}}
+	kv := gkv.WithContext(ctx, gkv.Wrap(gkv.Wrap(memory.Open())))
+	assert.NotPanics(t, func() {
+		assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+		assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	})
+}
@@ -0,0 +1,53 @@
+package gkv
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/WindomZ/gkv/codec"
+)
+
+// ErrNotFound key not found error
+var ErrNotFound = errors.New("key not found")
+
+var (
+	objectMu    sync.RWMutex
+	objectCodec = codec.JSON
+)
+
+// SetCodec sets the codec of PutObject, codec.JSON by default.
+// Values put by other codecs are still read by GetObject,
+// so a store can be migrated by getting and putting again.
+// It is safe to call concurrently with PutObject and GetObject.
+func SetCodec(c codec.Codec) {
+	objectMu.Lock()
+	objectCodec = c
+	objectMu.Unlock()
+}
+
+// getCodec returns the codec of SetCodec.
+func getCodec() codec.Codec {
+	objectMu.RLock()
+	defer objectMu.RUnlock()
+	return objectCodec
+}
+
+// PutObject sets the value for a key, marshaled by the codec of SetCodec.
+func PutObject(key []byte, v interface{}) error {
+	value, err := codec.Encode(getCodec(), v)
+	if err != nil {
+		return err
+	}
+	return Put(key, value)
+}
+
+// GetObject retrieves the value for a key, and unmarshals it into v by
+// the codec it was put with, values without the codec header are
+// unmarshaled by the codec of SetCodec.
+func GetObject(key []byte, v interface{}) error {
+	value := Get(key)
+	if value == nil {
+		return ErrNotFound
+	}
+	return codec.Decode(value, v, getCodec())
+}
@@ -0,0 +1,58 @@
+package gkv_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/codec"
+	_ "github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+func TestObject(t *testing.T) {
+	assert.NoError(t, gkv.Open([]byte("object")))
+	defer gkv.Close()
+
+	assert.NoError(t, gkv.PutObject([]byte("json"), user{Name: "json", Age: 1}))
+	gkv.SetCodec(codec.Gob)
+	defer gkv.SetCodec(codec.JSON)
+	assert.NoError(t, gkv.PutObject([]byte("gob"), user{Name: "gob", Age: 2}))
+	assert.NoError(t, gkv.Put([]byte("legacy"), []byte{}))
+
+	var u user
+	assert.NoError(t, gkv.GetObject([]byte("json"), &u))
+	assert.Equal(t, user{Name: "json", Age: 1}, u)
+	assert.NoError(t, gkv.GetObject([]byte("gob"), &u))
+	assert.Equal(t, user{Name: "gob", Age: 2}, u)
+	assert.Error(t, gkv.GetObject([]byte("legacy"), &u))
+	assert.Equal(t, gkv.ErrNotFound, gkv.GetObject([]byte("missing"), &u))
+}
+
+func TestSetCodecConcurrent(t *testing.T) {
+	assert.NoError(t, gkv.Open([]byte("object")))
+	defer gkv.Close()
+	defer gkv.SetCodec(codec.JSON)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if i%2 == 0 {
+					gkv.SetCodec(codec.Gob)
+				} else {
+					gkv.SetCodec(codec.JSON)
+				}
+				key := []byte(fmt.Sprintf("user%d", i))
+				assert.NoError(t, gkv.PutObject(key, user{Name: "user", Age: j}))
+				var u user
+				assert.NoError(t, gkv.GetObject(key, &u))
+				assert.Equal(t, j, u.Age)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,202 @@
+package pebble
+
+import (
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/WindomZ/gkv"
+	"github.com/cockroachdb/pebble"
+)
+
+// KV is cockroachdb/pebble adapter.
+type KV struct {
+	db *pebble.DB
+	wo atomic.Pointer[pebble.WriteOptions]
+}
+
+// Open creates a new pebble driver by storage file path.
+// paths are storage file paths.
+func Open(paths ...string) gkv.KV {
+	var path string
+	if len(paths) != 0 {
+		path = paths[0]
+	} else {
+		path = filepath.Join(gkv.ProjectDir(), "data", "pebble")
+	}
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		panic(err)
+	}
+	kv := &KV{
+		db: db,
+	}
+	kv.wo.Store(pebble.NoSync)
+	return kv
+}
+
+// DB returns the native DB of the adapter.
+func (kv *KV) DB() interface{} {
+	return kv.db
+}
+
+// Close releases all database resources.
+func (kv *KV) Close() error {
+	return kv.db.Close()
+}
+
+// Register initializes a new database if it doesn't already exist.
+func (kv *KV) Register(table []byte) error {
+	return nil
+}
+
+// Put sets the value for a key.
+func (kv *KV) Put(key, value []byte) error {
+	return kv.db.Set(key, value, kv.wo.Load())
+}
+
+// Get retrieves the value for a key.
+func (kv *KV) Get(key []byte) (value []byte) {
+	v, closer, err := kv.db.Get(key)
+	if err != nil {
+		return
+	}
+	value = append([]byte{}, v...)
+	closer.Close()
+	return
+}
+
+// Delete deletes the given key from the database resources.
+func (kv *KV) Delete(key []byte) error {
+	return kv.db.Delete(key, kv.wo.Load())
+}
+
+// Count returns the total number of all the keys.
+func (kv *KV) Count() (i int) {
+	iter, err := kv.db.NewIter(nil)
+	if err != nil {
+		return
+	}
+	for iter.First(); iter.Valid(); iter.Next() {
+		i++
+	}
+	iter.Close()
+	return
+}
+
+// Iterator creates an iterator for iterating over all the keys.
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	return kv.iterate(nil, f)
+}
+
+// Prefix creates an iterator for iterating over the keys with the prefix.
+func (kv *KV) Prefix(prefix []byte, f func([]byte, []byte) error) error {
+	return kv.iterate(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: successor(prefix),
+	}, f)
+}
+
+// iterate iterates over the keys in the bounds of opts.
+func (kv *KV) iterate(opts *pebble.IterOptions, f func([]byte, []byte) error) (err error) {
+	iter, err := kv.db.NewIter(opts)
+	if err != nil {
+		return err
+	}
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err = f(iter.Key(), iter.Value()); err != nil {
+			break
+		}
+	}
+	if e := iter.Close(); err == nil {
+		err = e
+	} else if err == gkv.ErrStopIteration {
+		err = nil
+	}
+	return
+}
+
+// successor returns the smallest key greater than all the keys with the prefix,
+// or nil if there is no such key.
+func successor(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte{}, prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// Write writes all the writes of the batch atomically.
+func (kv *KV) Write(b *gkv.Batch) error {
+	batch := kv.db.NewBatch()
+	defer batch.Close()
+	if err := b.Replay(func(key, value []byte) error {
+		return batch.Set(key, value, nil)
+	}, func(key []byte) error {
+		return batch.Delete(key, nil)
+	}); err != nil {
+		return err
+	}
+	return batch.Commit(kv.wo.Load())
+}
+
+// SetSync sets whether every write is flushed to stable storage.
+func (kv *KV) SetSync(sync bool) {
+	if sync {
+		kv.wo.Store(pebble.Sync)
+	} else {
+		kv.wo.Store(pebble.NoSync)
+	}
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	return kv.db.LogData(nil, pebble.Sync)
+}
+
+// Compact reclaims the unused space of the storage.
+func (kv *KV) Compact() error {
+	iter, err := kv.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	var start, end []byte
+	if iter.First() {
+		start = append(start, iter.Key()...)
+	}
+	if iter.Last() {
+		end = append(append(end, iter.Key()...), 0)
+	}
+	if err = iter.Close(); err != nil || start == nil {
+		return err
+	}
+	return kv.db.Compact(start, end, true)
+}
+
+// Stats returns the statistics of the storage, the free space is
+// the obsolete tables, and the hit rate is the one of the block cache.
+func (kv *KV) Stats() gkv.Stats {
+	m := kv.db.Metrics()
+	s := gkv.Stats{
+		Keys: kv.Count(),
+		Size: int64(m.DiskSpaceUsage()),
+		Free: int64(m.Table.ObsoleteSize),
+		Backend: map[string]interface{}{
+			"tables":        m.Total().NumFiles,
+			"wal_size":      m.WAL.Size,
+			"memtable_size": m.MemTable.Size,
+			"compactions":   m.Compact.Count,
+			"flushes":       m.Flush.Count,
+		},
+	}
+	if n := m.BlockCache.Hits + m.BlockCache.Misses; n != 0 {
+		s.HitRate = float64(m.BlockCache.Hits) / float64(n)
+	}
+	return s
+}
+
+func init() {
+	gkv.Register(Open)
+}
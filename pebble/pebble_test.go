@@ -0,0 +1,121 @@
+package pebble
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/testify/assert"
+)
+
+var demo *KV
+var (
+	demoTable = []byte("table-表_1 2%3")
+	demoKey   = []byte("key-键_4 5%6")
+	demoValue = []byte("value-值_7 8%9")
+)
+
+func TestOpen(t *testing.T) {
+	db := Open("../data/pebble.db")
+	if v, ok := db.(*KV); ok {
+		demo = v
+	}
+}
+
+func TestDB(t *testing.T) {
+	assert.NotEmpty(t, demo.DB())
+}
+
+func TestRegister(t *testing.T) {
+	assert.NoError(t, demo.Register(demoTable))
+}
+
+func TestPut(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+}
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 1, demo.Count())
+}
+
+func TestIterator(t *testing.T) {
+	cnt := 0
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		cnt++
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
+	}))
+	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestWrite(t *testing.T) {
+	var b gkv.Batch
+	b.Put([]byte("batch-1"), demoValue)
+	b.Put([]byte("batch-2"), demoValue)
+	b.Delete([]byte("batch-1"))
+	assert.NoError(t, demo.Write(&b))
+	assert.Nil(t, demo.Get([]byte("batch-1")))
+	assert.Equal(t, demoValue, demo.Get([]byte("batch-2")))
+	assert.Equal(t, 2, demo.Count())
+}
+
+func TestPrefix(t *testing.T) {
+	var keys []string
+	assert.NoError(t, demo.Prefix([]byte("batch-"), func(k []byte, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}))
+	assert.Equal(t, []string{"batch-2"}, keys)
+	assert.NoError(t, demo.Delete([]byte("batch-2")))
+	assert.Equal(t, []byte{0x01}, successor([]byte{0x00, 0xff}))
+	assert.Nil(t, successor([]byte{0xff}))
+}
+
+func TestDelete(t *testing.T) {
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.Equal(t, 0, demo.Count())
+}
+
+func TestSync(t *testing.T) {
+	demo.SetSync(true)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Contains(t, s.Backend, "tables")
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestClose(t *testing.T) {
+	assert.NoError(t, demo.Close())
+}
@@ -0,0 +1,29 @@
+//go:build !purego
+// +build !purego
+
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// driverName is the name of mattn/go-sqlite3 in database/sql.
+const driverName = "sqlite3"
+
+// pragmas returns the DSN params of mattn/go-sqlite3 for the options.
+func pragmas(opts Options) (params []string) {
+	if opts.JournalMode != "" {
+		params = append(params, "_journal_mode="+opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d",
+			opts.BusyTimeout/time.Millisecond))
+	}
+	if opts.Synchronous != "" {
+		params = append(params, "_sync="+opts.Synchronous)
+	}
+	return
+}
@@ -0,0 +1,29 @@
+//go:build purego
+// +build purego
+
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// driverName is the name of modernc.org/sqlite in database/sql.
+const driverName = "sqlite"
+
+// pragmas returns the DSN params of modernc.org/sqlite for the options.
+func pragmas(opts Options) (params []string) {
+	if opts.JournalMode != "" {
+		params = append(params, "_pragma=journal_mode("+opts.JournalMode+")")
+	}
+	if opts.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_pragma=busy_timeout(%d)",
+			opts.BusyTimeout/time.Millisecond))
+	}
+	if opts.Synchronous != "" {
+		params = append(params, "_pragma=synchronous("+opts.Synchronous+")")
+	}
+	return
+}
@@ -1,147 +1,342 @@
 package sqlite
 
 import (
-	"crypto/md5"
+	"bytes"
+	"context"
 	"database/sql"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/WindomZ/gkv"
-	"github.com/mattn/go-sqlite3"
 )
 
-// KV is mattn/go-sqlite3 adapter.
+// errRegister is returned when a table is used before it is registered.
+var errRegister = errors.New("sqlite: the table is not registered")
+
+// Options are params for creating a sqlite3 driver.
+type Options struct {
+	// JournalMode is the journal mode of the database,
+	// such as WAL, DELETE or TRUNCATE.
+	JournalMode string
+	// BusyTimeout is how long to wait for a locked database.
+	BusyTimeout time.Duration
+	// Synchronous is the synchronous flag of the database,
+	// such as FULL, NORMAL or OFF, the driver's default if empty.
+	Synchronous string
+}
+
+// DefaultOptions sets a list of recommended options for good performance.
+var DefaultOptions = Options{
+	JournalMode: "WAL",
+	BusyTimeout: 5 * time.Second,
+}
+
+// KV is sqlite3 adapter, the driver is mattn/go-sqlite3 using cgo,
+// or modernc.org/sqlite in pure Go with the purego build tag.
 type KV struct {
-	db    *sql.DB
-	table []byte
+	db *sql.DB
+	// conn is the connection of the writes, so that the synchronous flag
+	// set by SetSync on it applies to all of them.
+	conn  *sql.Conn
+	path  string
+	table string
+
+	put, get, del, count, iter *sql.Stmt
+
+	mu sync.Mutex
+	// syncErr is the error of SetSync, returned by the next Sync.
+	syncErr error
 }
 
 // Open creates a new sqlite3 driver by storage file path.
 // paths are storage file paths.
 func Open(paths ...string) gkv.KV {
+	return OpenOptions(DefaultOptions, paths...)
+}
+
+// OpenOptions creates a new sqlite3 driver by options and storage file path.
+// paths are storage file paths.
+func OpenOptions(opts Options, paths ...string) gkv.KV {
 	var path string
 	if len(paths) != 0 {
 		path = paths[0]
 	} else {
 		path = filepath.Join(gkv.ProjectDir(), "data", "data.db")
 	}
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open(driverName, dsn(path, opts))
+	if err != nil {
+		panic(err)
+	}
+	conn, err := db.Conn(context.Background())
 	if err != nil {
-		panic(sqlite3.ErrError)
+		panic(err)
 	}
 	return &KV{
 		db:    db,
-		table: []byte(gkv.DefaultTableName),
+		conn:  conn,
+		path:  path,
+		table: `"` + gkv.DefaultTableName + `"`,
+	}
+}
+
+// dsn returns the data source name of the path with the options.
+func dsn(path string, opts Options) string {
+	params := pragmas(opts)
+	if len(params) == 0 {
+		return path
+	}
+	if strings.Contains(path, "?") {
+		return path + "&" + strings.Join(params, "&")
 	}
+	return path + "?" + strings.Join(params, "&")
 }
 
 // DB returns the native DB of the adapter.
-func (kv KV) DB() interface{} {
+func (kv *KV) DB() interface{} {
 	return kv.db
 }
 
 // Close releases all database resources.
 func (kv *KV) Close() error {
+	kv.closeStmts()
+	kv.conn.Close()
 	return kv.db.Close()
 }
 
+// quote returns the table name as a quoted SQL identifier,
+// or gkv.ErrTableName if it cannot be represented.
+func quote(table []byte) (string, error) {
+	if len(table) == 0 || !utf8.Valid(table) || bytes.IndexByte(table, 0) >= 0 ||
+		bytes.HasPrefix(bytes.ToLower(table), []byte("sqlite_")) {
+		return "", gkv.ErrTableName
+	}
+	return `"` + strings.Replace(string(table), `"`, `""`, -1) + `"`, nil
+}
+
 // Register initializes a new database if it doesn't already exist.
 func (kv *KV) Register(table []byte) error {
-	if len(table) == 0 {
-		return gkv.ErrTableName
-	}
-	kv.table = table
-	_, err := kv.db.Exec(fmt.Sprintf(`
-PRAGMA foreign_keys = FALSE;
-CREATE TABLE IF NOT EXISTS %s (
-	id VARCHAR(34) NOT NULL,
-	k TEXT NOT NULL,
-	v TEXT NOT NULL,
-	PRIMARY KEY (id)
-);
-PRAGMA foreign_keys = TRUE;
-`, string(table)))
-	return err
+	name, err := quote(table)
+	if err != nil {
+		return err
+	}
+	kv.table = name
+	if err = kv.migrate(table); err != nil {
+		return err
+	}
+	_, err = kv.db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (k BLOB NOT NULL PRIMARY KEY, v BLOB)",
+		kv.table),
+	)
+	if err != nil {
+		return err
+	}
+	return kv.prepare()
 }
 
-func (kv *KV) id(b []byte) string {
-	h := md5.New()
-	h.Write(b)
-	return hex.EncodeToString(h.Sum(nil))
+// prepare prepares the statements of the registered table once,
+// so that they are not parsed again on every operation.
+func (kv *KV) prepare() (err error) {
+	kv.closeStmts()
+	ctx := context.Background()
+	for _, s := range []struct {
+		stmt    **sql.Stmt
+		query   string
+		prepare func(context.Context, string) (*sql.Stmt, error)
+	}{
+		{&kv.put, "REPLACE INTO %s (k, v) VALUES (?, ?)", kv.conn.PrepareContext},
+		{&kv.get, "SELECT v FROM %s WHERE k = ?", kv.db.PrepareContext},
+		{&kv.del, "DELETE FROM %s WHERE k = ?", kv.conn.PrepareContext},
+		{&kv.count, "SELECT COUNT(*) FROM %s", kv.db.PrepareContext},
+		{&kv.iter, "SELECT k, v FROM %s ORDER BY k", kv.db.PrepareContext},
+	} {
+		if *s.stmt, err = s.prepare(ctx, fmt.Sprintf(s.query, kv.table)); err != nil {
+			kv.closeStmts()
+			return
+		}
+	}
+	return
+}
+
+// closeStmts closes the prepared statements of the registered table.
+func (kv *KV) closeStmts() {
+	for _, stmt := range []**sql.Stmt{&kv.put, &kv.get, &kv.del, &kv.count, &kv.iter} {
+		if *stmt != nil {
+			(*stmt).Close()
+			*stmt = nil
+		}
+	}
+}
+
+// migrate converts a table of the legacy schema,
+// which was keyed by the md5 of the key and stored keys and values as TEXT,
+// into the schema keyed by the key itself.
+func (kv *KV) migrate(table []byte) error {
+	rows, err := kv.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", kv.table))
+	if err != nil {
+		return err
+	}
+	var legacy bool
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			value            interface{}
+		)
+		if err = rows.Scan(&cid, &name, &typ, &notNull, &value, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == "id" {
+			legacy = true
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || !legacy {
+		return err
+	}
+
+	tmp, err := quote(append(append([]byte{}, table...), "_gkv_migrate"...))
+	if err != nil {
+		return err
+	}
+	tx, err := kv.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		fmt.Sprintf("CREATE TABLE %s (k BLOB NOT NULL PRIMARY KEY, v BLOB)", tmp),
+		fmt.Sprintf("INSERT OR REPLACE INTO %s (k, v) SELECT CAST(k AS BLOB), CAST(v AS BLOB) FROM %s",
+			tmp, kv.table),
+		fmt.Sprintf("DROP TABLE %s", kv.table),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, kv.table),
+	} {
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // Put sets the value for a key.
 func (kv *KV) Put(key, value []byte) error {
-	_, err := kv.db.Exec(
-		fmt.Sprintf("REPLACE INTO %s(id, k, v) VALUES (?,?,?)", string(kv.table)),
-		kv.id(key), gkv.Btos(key), gkv.Btos(value),
-	)
+	if kv.put == nil {
+		return errRegister
+	}
+	_, err := kv.put.Exec(key, value)
 	return err
 }
 
 // Get retrieves the value for a key.
 func (kv *KV) Get(key []byte) (value []byte) {
-	rows, err := kv.db.Query(
-		fmt.Sprintf("SELECT v FROM %s WHERE id=? LIMIT 1", string(kv.table)),
-		kv.id(key),
-	)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-	if rows.Next() {
-		var s string
-		err = rows.Scan(&s)
-		value = gkv.Stob(s)
+	if kv.get != nil {
+		kv.get.QueryRow(key).Scan(&value)
 	}
 	return
 }
 
 // Delete deletes the given key from the database resources.
 func (kv *KV) Delete(key []byte) error {
-	_, err := kv.db.Exec(
-		fmt.Sprintf("DELETE FROM %s WHERE id=?", string(kv.table)),
-		kv.id(key),
-	)
+	if kv.del == nil {
+		return errRegister
+	}
+	_, err := kv.del.Exec(key)
 	return err
 }
 
 // Count returns the total number of all the keys.
 func (kv *KV) Count() (i int) {
-	rows, err := kv.db.Query(
-		fmt.Sprintf("SELECT COUNT(*) FROM %s LIMIT 1", string(kv.table)),
-	)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-	if rows.Next() {
-		err = rows.Scan(&i)
+	if kv.count != nil {
+		kv.count.QueryRow().Scan(&i)
 	}
 	return
 }
 
 // Iterator creates an iterator for iterating over all the keys.
-func (kv *KV) Iterator(f func([]byte, []byte) bool) error {
-	rows, err := kv.db.Query(
-		fmt.Sprintf("SELECT k, v FROM %s", string(kv.table)),
-	)
+func (kv *KV) Iterator(f func([]byte, []byte) error) error {
+	if kv.iter == nil {
+		return errRegister
+	}
+	rows, err := kv.iter.Query()
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 	var k, v []byte
 	for rows.Next() {
-		if err = rows.Scan(&k, &v); err == nil {
-			if !f(k, v) {
-				break
+		if err = rows.Scan(&k, &v); err != nil {
+			return err
+		}
+		if err = f(k, v); err != nil {
+			if err == gkv.ErrStopIteration {
+				return nil
 			}
+			return err
 		}
 	}
+	return rows.Err()
+}
+
+// SetSync sets whether every write is flushed to stable storage,
+// by the synchronous flag FULL, or NORMAL if not, of the connection of the writes.
+// Its error is returned by the next Sync.
+func (kv *KV) SetSync(sync bool) {
+	mode := "NORMAL"
+	if sync {
+		mode = "FULL"
+	}
+	_, err := kv.conn.ExecContext(context.Background(), "PRAGMA synchronous = "+mode)
+	kv.mu.Lock()
+	kv.syncErr = err
+	kv.mu.Unlock()
+}
+
+// Sync flushes all pending writes to stable storage.
+func (kv *KV) Sync() error {
+	kv.mu.Lock()
+	err := kv.syncErr
+	kv.syncErr = nil
+	kv.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := gkv.SyncFile(kv.path); err != nil {
+		return err
+	}
+	return gkv.SyncFile(kv.path + "-wal")
+}
+
+// Compact reclaims the unused space of the storage.
+func (kv *KV) Compact() error {
+	_, err := kv.db.Exec("VACUUM")
 	return err
 }
 
+// Stats returns the statistics of the storage, the free space is
+// the pages on the freelist, which VACUUM reclaims.
+func (kv *KV) Stats() gkv.Stats {
+	var pageCount, freelistCount, pageSize int64
+	kv.db.QueryRow("PRAGMA page_count").Scan(&pageCount)
+	kv.db.QueryRow("PRAGMA freelist_count").Scan(&freelistCount)
+	kv.db.QueryRow("PRAGMA page_size").Scan(&pageSize)
+	return gkv.Stats{
+		Keys: kv.Count(),
+		Size: pageCount * pageSize,
+		Free: freelistCount * pageSize,
+		Backend: map[string]interface{}{
+			"page_count":     pageCount,
+			"freelist_count": freelistCount,
+			"page_size":      pageSize,
+			"wal_size":       gkv.DiskSize(kv.path + "-wal"),
+		},
+	}
+}
+
 func init() {
 	gkv.Register(Open)
 }
@@ -1,14 +1,20 @@
 package sqlite
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/WindomZ/gkv"
 	"github.com/WindomZ/testify/assert"
 )
 
 var demo *KV
 var (
-	demoTable = []byte("table表123")
+	demoTable = []byte("table-表_1 2%3")
 	demoKey   = []byte("key-键_4 5%6")
 	demoValue = []byte("value-值_7 8%9")
 )
@@ -25,6 +31,31 @@ func TestDB(t *testing.T) {
 }
 
 func TestRegister(t *testing.T) {
+	assert.Equal(t, gkv.ErrTableName, demo.Register(nil))
+	assert.Equal(t, gkv.ErrTableName, demo.Register([]byte("table\x00")))
+	assert.Equal(t, gkv.ErrTableName, demo.Register([]byte("\xff\xfe")))
+	assert.Equal(t, gkv.ErrTableName, demo.Register([]byte("sqlite_master")))
+	assert.NoError(t, demo.Register([]byte(`table"; DROP TABLE "x`)))
+	assert.NoError(t, demo.Register(demoTable))
+}
+
+func TestMigrate(t *testing.T) {
+	legacy := []byte("legacy")
+	_, err := demo.db.Exec(`
+DROP TABLE IF EXISTS legacy;
+CREATE TABLE legacy (
+	id VARCHAR(34) NOT NULL,
+	k TEXT NOT NULL,
+	v TEXT NOT NULL,
+	PRIMARY KEY (id)
+);
+INSERT INTO legacy (id, k, v) VALUES ('d41d8cd98f00b204e9800998ecf8427e', 'key', 'value');
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, demo.Register(legacy))
+	assert.Equal(t, []byte("value"), demo.Get([]byte("key")))
+	assert.NoError(t, demo.Register(legacy))
+	assert.Equal(t, 1, demo.Count())
 	assert.NoError(t, demo.Register(demoTable))
 }
 
@@ -42,12 +73,47 @@ func TestCount(t *testing.T) {
 
 func TestIterator(t *testing.T) {
 	cnt := 0
-	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) bool {
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
 		cnt++
-		return assert.Equal(t, demoKey, k) &&
-			assert.Equal(t, demoValue, v)
+		assert.Equal(t, demoKey, k)
+		assert.Equal(t, demoValue, v)
+		return nil
 	}))
 	assert.Equal(t, 1, cnt)
+
+	assert.NoError(t, demo.Iterator(func(k []byte, v []byte) error {
+		return gkv.ErrStopIteration
+	}))
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, demo.Iterator(func(k []byte, v []byte) error {
+		return errStop
+	}))
+}
+
+func TestIteratorNested(t *testing.T) {
+	done := make(chan error, 1)
+	go func() {
+		done <- demo.Iterator(func(k []byte, v []byte) error {
+			assert.Equal(t, v, demo.Get(k))
+			assert.Equal(t, 1, demo.Count())
+			return demo.Put(k, v)
+		})
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the calls in the iterator are deadlocked")
+	}
+}
+
+func TestBinary(t *testing.T) {
+	key := []byte{0x00, 0xff, 'k'}
+	value := []byte{0xff, 0x00, 0xfe}
+	assert.NoError(t, demo.Put(key, value))
+	assert.Equal(t, value, demo.Get(key))
+	assert.NoError(t, demo.Delete(key))
+	assert.Nil(t, demo.Get(key))
 }
 
 func TestDelete(t *testing.T) {
@@ -55,6 +121,97 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, demo.Count())
 }
 
+func TestSync(t *testing.T) {
+	db := demo.DB()
+	demo.SetSync(true)
+	var synchronous int
+	assert.NoError(t, demo.conn.QueryRowContext(context.Background(), "PRAGMA synchronous").Scan(&synchronous))
+	assert.Equal(t, 2, synchronous)
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Sync())
+	demo.SetSync(false)
+	assert.NoError(t, demo.conn.QueryRowContext(context.Background(), "PRAGMA synchronous").Scan(&synchronous))
+	assert.Equal(t, 1, synchronous)
+	// the handle of DB is still valid.
+	assert.Equal(t, db, demo.DB())
+	assert.NoError(t, demo.DB().(*sql.DB).Ping())
+	assert.NoError(t, demo.Delete(demoKey))
+	assert.NoError(t, demo.Sync())
+}
+
+func TestCompact(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	assert.NoError(t, demo.Compact())
+	assert.Equal(t, demoValue, demo.Get(demoKey))
+	assert.Equal(t, 1, demo.Count())
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
+func TestStats(t *testing.T) {
+	assert.NoError(t, demo.Put(demoKey, demoValue))
+	s := demo.Stats()
+	assert.Equal(t, demo.Count(), s.Keys)
+	assert.True(t, s.Keys >= 1)
+	assert.True(t, s.Size > 0)
+	assert.Equal(t, s.Size, s.Backend["page_count"].(int64)*s.Backend["page_size"].(int64))
+	assert.True(t, s.Fragmentation() < 1)
+	assert.NoError(t, demo.Delete(demoKey))
+}
+
 func TestClose(t *testing.T) {
 	assert.NoError(t, demo.Close())
 }
+
+func benchmarkOpen(b *testing.B, opts Options) *KV {
+	kv := OpenOptions(opts, "../data/bench-sqlite.db").(*KV)
+	if err := kv.Register(demoTable); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	return kv
+}
+
+func BenchmarkPut(b *testing.B) {
+	kv := benchmarkOpen(b, DefaultOptions)
+	defer kv.Close()
+	for i := 0; i < b.N; i++ {
+		kv.Put(demoKey, demoValue)
+	}
+}
+
+func BenchmarkPutUnprepared(b *testing.B) {
+	kv := benchmarkOpen(b, DefaultOptions)
+	defer kv.Close()
+	for i := 0; i < b.N; i++ {
+		kv.db.Exec(fmt.Sprintf("REPLACE INTO %s (k, v) VALUES (?, ?)", kv.table),
+			demoKey, demoValue)
+	}
+}
+
+func BenchmarkPutRollbackJournal(b *testing.B) {
+	kv := benchmarkOpen(b, Options{JournalMode: "DELETE"})
+	defer kv.Close()
+	for i := 0; i < b.N; i++ {
+		kv.Put(demoKey, demoValue)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	kv := benchmarkOpen(b, DefaultOptions)
+	defer kv.Close()
+	kv.Put(demoKey, demoValue)
+	for i := 0; i < b.N; i++ {
+		kv.Get(demoKey)
+	}
+}
+
+func BenchmarkGetUnprepared(b *testing.B) {
+	kv := benchmarkOpen(b, DefaultOptions)
+	defer kv.Close()
+	kv.Put(demoKey, demoValue)
+	for i := 0; i < b.N; i++ {
+		var value []byte
+		kv.db.QueryRow(fmt.Sprintf("SELECT v FROM %s WHERE k = ?", kv.table),
+			demoKey).Scan(&value)
+	}
+}
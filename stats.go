@@ -0,0 +1,25 @@
+package gkv
+
+// Stats are the statistics of the storage of a KV.
+type Stats struct {
+	// Keys is the number of the keys of the table registered.
+	Keys int
+	// Size is the size in bytes of the storage, on disk or in memory.
+	Size int64
+	// Free is the size in bytes of the free or discardable space of Size,
+	// which is reclaimed by Compact.
+	Free int64
+	// HitRate is the ratio of the cache hits to the lookups,
+	// zero unless there is a cache.
+	HitRate float64
+	// Backend are the statistics specific to the adapter.
+	Backend map[string]interface{}
+}
+
+// Fragmentation returns the ratio of Free to Size.
+func (s Stats) Fragmentation() float64 {
+	if s.Size == 0 {
+		return 0
+	}
+	return float64(s.Free) / float64(s.Size)
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"github.com/WindomZ/gkv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the tracer.
+const instrumentation = "github.com/WindomZ/gkv/tracing"
+
+// The attributes of the spans.
+const (
+	AttrDriver    = attribute.Key("gkv.driver")
+	AttrTable     = attribute.Key("gkv.table")
+	AttrOperation = attribute.Key("gkv.operation")
+	AttrKeySize   = attribute.Key("gkv.key_size")
+	AttrValueSize = attribute.Key("gkv.value_size")
+	AttrCount     = attribute.Key("gkv.count")
+	AttrBatchSize = attribute.Key("gkv.batch_size")
+	AttrResult    = attribute.Key("gkv.result")
+)
+
+// The results of the operations.
+const (
+	ResultOK       = "ok"
+	ResultNotFound = "not_found"
+	ResultError    = "error"
+)
+
+// Options are the options of the tracing layer.
+type Options struct {
+	// TracerProvider provides the tracer, otel.GetTracerProvider() if nil.
+	TracerProvider trace.TracerProvider
+}
+
+// Middleware returns a gkv.Middleware starting a span for every operation,
+// as a child of the span in the context of the Call, see gkv.WithContext.
+func Middleware(opts Options) gkv.Middleware {
+	tp := opts.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(instrumentation)
+	return func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			ctx, span := tracer.Start(c.Ctx, "gkv."+c.Op.String(),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					AttrDriver.String(c.Driver),
+					AttrTable.String(string(c.Table)),
+					AttrOperation.String(c.Op.String()),
+				))
+			defer span.End()
+			c.Ctx = ctx
+
+			count := 0
+			switch c.Op {
+			case gkv.OpPut:
+				span.SetAttributes(AttrKeySize.Int(len(c.Key)), AttrValueSize.Int(len(c.Value)))
+			case gkv.OpGet, gkv.OpDelete, gkv.OpPrefix:
+				span.SetAttributes(AttrKeySize.Int(len(c.Key)))
+			case gkv.OpWrite:
+				span.SetAttributes(AttrBatchSize.Int(c.Batch.Len()))
+			}
+			if c.Iter != nil {
+				f := c.Iter
+				c.Iter = func(k, v []byte) error {
+					count++
+					return f(k, v)
+				}
+			}
+
+			err := next(c)
+			switch c.Op {
+			case gkv.OpGet:
+				span.SetAttributes(AttrValueSize.Int(len(c.Value)))
+			case gkv.OpCount:
+				span.SetAttributes(AttrCount.Int(c.Count))
+			case gkv.OpIterator, gkv.OpPrefix:
+				span.SetAttributes(AttrCount.Int(count))
+			}
+			switch {
+			case err != nil:
+				span.SetAttributes(AttrResult.String(ResultError))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			case c.Op == gkv.OpGet && c.Value == nil:
+				span.SetAttributes(AttrResult.String(ResultNotFound))
+			default:
+				span.SetAttributes(AttrResult.String(ResultOK))
+			}
+			return err
+		}
+	}
+}
+
+// Wrap returns kv with a span started for every operation.
+func Wrap(opts Options, kv gkv.KV) gkv.KV {
+	return gkv.Wrap(kv, Middleware(opts))
+}
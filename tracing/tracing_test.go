@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errDemo = errors.New("demo")
+
+func newKV() (gkv.KV, *tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return Wrap(Options{TracerProvider: tp}, memory.Open()), exporter, tp
+}
+
+// attrs returns the attributes of the span.
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestWrap(t *testing.T) {
+	kv, exporter, _ := newKV()
+	assert.NoError(t, kv.Register([]byte("table")))
+	assert.NoError(t, kv.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, []byte("value"), kv.Get([]byte("key")))
+	assert.Nil(t, kv.Get([]byte("missing")))
+	assert.Equal(t, 1, kv.Count())
+	assert.Equal(t, errDemo, kv.Iterator(func(k, v []byte) error {
+		return errDemo
+	}))
+	b := new(gkv.Batch)
+	b.Put([]byte("key2"), []byte("value2"))
+	assert.NoError(t, kv.(gkv.Batcher).Write(b))
+	assert.NoError(t, gkv.PrefixOf(kv, []byte("key"), func(k, v []byte) error { return nil }))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 7)
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	assert.Equal(t, []string{
+		"gkv.put", "gkv.get", "gkv.get", "gkv.count",
+		"gkv.iterator", "gkv.write", "gkv.prefix",
+	}, names)
+
+	put := attrs(spans[0])
+	assert.Equal(t, "memory", put[AttrDriver].AsString())
+	assert.Equal(t, "table", put[AttrTable].AsString())
+	assert.Equal(t, int64(3), put[AttrKeySize].AsInt64())
+	assert.Equal(t, int64(5), put[AttrValueSize].AsInt64())
+	assert.Equal(t, ResultOK, put[AttrResult].AsString())
+
+	assert.Equal(t, int64(5), attrs(spans[1])[AttrValueSize].AsInt64())
+	assert.Equal(t, ResultNotFound, attrs(spans[2])[AttrResult].AsString())
+	assert.Equal(t, int64(1), attrs(spans[3])[AttrCount].AsInt64())
+
+	assert.Equal(t, ResultError, attrs(spans[4])[AttrResult].AsString())
+	assert.Equal(t, codes.Error, spans[4].Status.Code)
+	assert.Len(t, spans[4].Events, 1)
+
+	assert.Equal(t, int64(1), attrs(spans[5])[AttrBatchSize].AsInt64())
+	assert.Equal(t, int64(2), attrs(spans[6])[AttrCount].AsInt64())
+}
+
+func TestWithContext(t *testing.T) {
+	kv, exporter, tp := newKV()
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	assert.NoError(t, gkv.WithContext(ctx, kv).Put([]byte("key"), []byte("value")))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "gkv.put", spans[0].Name)
+	assert.Equal(t, spans[1].SpanContext.TraceID(), spans[0].SpanContext.TraceID())
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+
+	// the span is in the context of the next middlewares.
+	var inner context.Context
+	kv = gkv.Wrap(memory.Open(), Middleware(Options{TracerProvider: tp}), func(next gkv.Handler) gkv.Handler {
+		return func(c *gkv.Call) error {
+			inner = c.Ctx
+			return next(c)
+		}
+	})
+	kv.Count()
+	spans = exporter.GetSpans()
+	assert.Equal(t, spans[len(spans)-1].SpanContext.SpanID(), trace.SpanContextFromContext(inner).SpanID())
+}
@@ -0,0 +1,176 @@
+package gkv
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/WindomZ/gkv/codec"
+)
+
+// ErrDecode illegal encoded value error
+var ErrDecode = errors.New("illegal encoded value")
+
+// Codec encodes a value of type T into bytes and decodes it back.
+type Codec[T any] interface {
+	// Encode returns the bytes of the value.
+	Encode(T) ([]byte, error)
+	// Decode returns the value of the bytes.
+	Decode([]byte) (T, error)
+}
+
+// Typed wraps a KV with typed keys and values encoded by codecs.
+type Typed[K, V any] struct {
+	kv     KV
+	keys   Codec[K]
+	values Codec[V]
+}
+
+// NewTyped returns a Typed of kv with the codecs of keys and values.
+func NewTyped[K, V any](kv KV, keys Codec[K], values Codec[V]) *Typed[K, V] {
+	return &Typed[K, V]{
+		kv:     kv,
+		keys:   keys,
+		values: values,
+	}
+}
+
+// KV returns the wrapped KV.
+func (t *Typed[K, V]) KV() KV {
+	return t.kv
+}
+
+// Put sets the value for a key.
+func (t *Typed[K, V]) Put(key K, value V) error {
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	v, err := t.values.Encode(value)
+	if err != nil {
+		return err
+	}
+	return t.kv.Put(k, v)
+}
+
+// Get retrieves the value for a key, ok is false if the key doesn't exist.
+func (t *Typed[K, V]) Get(key K) (value V, ok bool, err error) {
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return
+	}
+	v := t.kv.Get(k)
+	if v == nil {
+		return
+	}
+	value, err = t.values.Decode(v)
+	return value, err == nil, err
+}
+
+// Delete deletes the given key from the database resources.
+func (t *Typed[K, V]) Delete(key K) error {
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	return t.kv.Delete(k)
+}
+
+// Count returns the total number of all the keys.
+func (t *Typed[K, V]) Count() int {
+	return t.kv.Count()
+}
+
+// Iterator creates an iterator for iterating over all the keys,
+// it stops at the first error of decoding as well.
+func (t *Typed[K, V]) Iterator(f func(K, V) error) error {
+	return t.kv.Iterator(func(k, v []byte) error {
+		key, err := t.keys.Decode(k)
+		if err != nil {
+			return err
+		}
+		value, err := t.values.Decode(v)
+		if err != nil {
+			return err
+		}
+		return f(key, value)
+	})
+}
+
+// BytesCodec is the Codec of []byte as is.
+type BytesCodec struct{}
+
+// Encode returns the bytes of the value.
+func (BytesCodec) Encode(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+// Decode returns the value of the bytes.
+func (BytesCodec) Decode(b []byte) ([]byte, error) {
+	return append([]byte{}, b...), nil
+}
+
+// StringCodec is the Codec of string as its bytes.
+type StringCodec struct{}
+
+// Encode returns the bytes of the value.
+func (StringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+// Decode returns the value of the bytes.
+func (StringCodec) Decode(b []byte) (string, error) {
+	return string(b), nil
+}
+
+// Int64Codec is the Codec of int64 as 8 big-endian bytes with the sign bit
+// flipped, so that the bytes sort in the same order as the numbers.
+type Int64Codec struct{}
+
+// Encode returns the bytes of the value.
+func (Int64Codec) Encode(v int64) ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v)^(1<<63))
+	return b, nil
+}
+
+// Decode returns the value of the bytes.
+func (Int64Codec) Decode(b []byte) (int64, error) {
+	if len(b) != 8 {
+		return 0, ErrDecode
+	}
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63)), nil
+}
+
+// Uint64Codec is the Codec of uint64 as 8 big-endian bytes,
+// so that the bytes sort in the same order as the numbers.
+type Uint64Codec struct{}
+
+// Encode returns the bytes of the value.
+func (Uint64Codec) Encode(v uint64) ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b, nil
+}
+
+// Decode returns the value of the bytes.
+func (Uint64Codec) Decode(b []byte) (uint64, error) {
+	if len(b) != 8 {
+		return 0, ErrDecode
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// JSONCodec is the Codec of T as JSON, which is codec.For[T](codec.JSON),
+// so its values are read together with the ones of the other codecs.
+type JSONCodec[T any] struct{}
+
+// Encode returns the bytes of the value with the header of codec.Encode.
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return codec.For[T](codec.JSON).Encode(v)
+}
+
+// Decode returns the value of the bytes, which is decoded by the codec of
+// the header, or as JSON without the header.
+func (JSONCodec[T]) Decode(b []byte) (T, error) {
+	return codec.For[T](codec.JSON).Decode(b)
+}
@@ -0,0 +1,83 @@
+package gkv_test
+
+import (
+	"testing"
+
+	"github.com/WindomZ/gkv"
+	"github.com/WindomZ/gkv/codec"
+	"github.com/WindomZ/gkv/memory"
+	"github.com/WindomZ/testify/assert"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestTyped(t *testing.T) {
+	users := gkv.NewTyped[int64, user](memory.Open(), gkv.Int64Codec{}, gkv.JSONCodec[user]{})
+	assert.NotNil(t, users.KV())
+
+	for _, id := range []int64{2, -1, 1} {
+		assert.NoError(t, users.Put(id, user{Name: "user", Age: int(id)}))
+	}
+	assert.Equal(t, 3, users.Count())
+
+	u, ok, err := users.Get(1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, user{Name: "user", Age: 1}, u)
+
+	_, ok, err = users.Get(3)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var ids []int64
+	assert.NoError(t, users.Iterator(func(id int64, u user) error {
+		ids = append(ids, id)
+		assert.Equal(t, int(id), u.Age)
+		return nil
+	}))
+	assert.Equal(t, []int64{-1, 1, 2}, ids)
+
+	assert.NoError(t, users.Delete(1))
+	assert.Equal(t, 2, users.Count())
+
+	// the values of the codecs of codec.For are read together.
+	gobs := gkv.NewTyped[int64, user](users.KV(), gkv.Int64Codec{}, codec.For[user](codec.Gob))
+	assert.NoError(t, gobs.Put(3, user{Name: "gob", Age: 3}))
+	u, ok, err = users.Get(3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, user{Name: "gob", Age: 3}, u)
+	u, ok, err = gobs.Get(2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, user{Name: "user", Age: 2}, u)
+}
+
+func TestCodec(t *testing.T) {
+	b, err := gkv.Uint64Codec{}.Encode(42)
+	assert.NoError(t, err)
+	n, err := gkv.Uint64Codec{}.Decode(b)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), n)
+
+	_, err = gkv.Int64Codec{}.Decode([]byte("short"))
+	assert.Equal(t, gkv.ErrDecode, err)
+
+	u, err := gkv.JSONCodec[user]{}.Decode([]byte(`{"name":"legacy"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", u.Name)
+
+	s, err := gkv.StringCodec{}.Decode([]byte("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key", s)
+
+	names := gkv.NewTyped[string, []byte](memory.Open(), gkv.StringCodec{}, gkv.BytesCodec{})
+	assert.NoError(t, names.Put("name", []byte("value")))
+	v, ok, err := names.Get("name")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), v)
+}
@@ -1,6 +1,7 @@
 package gkv
 
 import (
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
@@ -22,3 +23,30 @@ func Btos(b []byte) string {
 func Stob(s string) []byte {
 	return *(*[]byte)(unsafe.Pointer((*reflect.StringHeader)(unsafe.Pointer(&s))))
 }
+
+// SyncFile flushes the file or directory at path to stable storage.
+// A missing path is not an error.
+func SyncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// DiskSize returns the size in bytes of the file at path,
+// or of all the files under it if it is a directory.
+// A missing path is of size 0.
+func DiskSize(path string) (size int64) {
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}